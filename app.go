@@ -1,24 +1,38 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"expvar"
+	"fmt"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
-	"sort"
-	"sync"
+	"os/signal"
+	"strconv"
+	"syscall"
 	"time"
 
 	"github.com/shiimaxx/blog-aggregator/blogservice"
-	"github.com/shiimaxx/blog-aggregator/blogservice/hatenablog"
-	"github.com/shiimaxx/blog-aggregator/blogservice/qiita"
+	_ "github.com/shiimaxx/blog-aggregator/blogservice/hatenablog"
+	_ "github.com/shiimaxx/blog-aggregator/blogservice/qiita"
+	"github.com/shiimaxx/blog-aggregator/redis"
 	"github.com/shiimaxx/blog-aggregator/structs"
 )
 
 const defaultListenPort = "8080"
 const defaultCacheExpiration = 60 * time.Second
+const defaultFetchTimeout = 10 * time.Second
+const defaultRefreshInterval = 10 * time.Minute
+const defaultMaxStale = 24 * time.Hour
+const defaultCacheMaxEntries = 1000
+const defaultCacheCleanupInterval = 10 * time.Minute
+const defaultCacheDir = "/var/cache/blog-aggregator"
+const defaultRedisURL = "redis://localhost:6379/0"
+const redisLocalTTL = 10 * time.Second
+const shutdownTimeout = 10 * time.Second
 
 type server struct {
 	router      *http.ServeMux
@@ -27,38 +41,137 @@ type server struct {
 	config      config
 	cache       storage
 	blogService *blogservice.BlogService
+	scheduler   *scheduler
+	flight      flightGroup
 }
 
 type config struct {
-	originURL    string
-	qiitaID      string
-	hatenaID     string
-	hatenaBlogID string
-	hatenaAPIKey string
+	originURL       string
+	feedTitle       string
+	fetchTimeout    time.Duration
+	refreshInterval time.Duration
+	services        blogservice.Config
 }
 
 type entriesResponse struct {
 	Entries []structs.Entry `json:"entries"`
+	Total   int             `json:"total"`
+	Next    string          `json:"next,omitempty"`
+	Prev    string          `json:"prev,omitempty"`
+	Sources []sourceStatus  `json:"sources"`
 }
 
-func (s *server) blogservices() {
-	if s.config.qiitaID != "" {
-		s.blogService.Add(func() ([]structs.Entry, error) {
-			return qiita.FetchEntries(context.TODO(), s.config.qiitaID)
-		})
+type errorResponse struct {
+	Status  int            `json:"status"`
+	Error   string         `json:"error"`
+	Message string         `json:"message"`
+	Sources []sourceStatus `json:"sources,omitempty"`
+}
+
+type sourceStatus struct {
+	Name      string           `json:"name"`
+	Kind      string           `json:"kind"`
+	Status    string           `json:"status"`
+	Error     string           `json:"error,omitempty"`
+	Count     int              `json:"count"`
+	Circuit   string           `json:"circuit"`
+	RateLimit *rateLimitStatus `json:"rate_limit,omitempty"`
+}
+
+type rateLimitStatus struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+func newSourceStatuses(sources []blogservice.Source) []sourceStatus {
+	statuses := make([]sourceStatus, len(sources))
+	for i, src := range sources {
+		st := sourceStatus{
+			Name:    src.Name,
+			Kind:    src.Kind,
+			Status:  "ok",
+			Count:   src.Count,
+			Circuit: src.Breaker.String(),
+		}
+		if rl := src.RateLimit; rl != nil {
+			st.RateLimit = &rateLimitStatus{Limit: rl.Limit, Remaining: rl.Remaining, Reset: rl.Reset}
+		}
+		switch {
+		case src.Err != nil:
+			st.Status = "error"
+			st.Error = src.Err.Error()
+		case src.FetchedAt.IsZero():
+			st.Status = "pending"
+		}
+		statuses[i] = st
 	}
-	if s.config.hatenaID != "" {
-		s.blogService.Add(func() ([]structs.Entry, error) {
-			return hatenablog.FetchEntries(context.TODO(), s.config.hatenaID, s.config.hatenaBlogID, s.config.hatenaAPIKey)
-		})
+	return statuses
+}
+
+func (s *server) blogservices() error {
+	providers, err := blogservice.Open(s.config.services)
+	if err != nil {
+		return err
+	}
+	s.blogService.Timeout = s.config.fetchTimeout
+	for _, p := range providers {
+		s.logger.Printf("[INFO] %s %s", "enabled blog service", p.Name())
+		s.blogService.Add(p)
+	}
+	if s.config.refreshInterval > 0 {
+		s.scheduler = newScheduler(providers, s.config.refreshInterval, s.refresh)
 	}
+	return nil
+}
+
+// run serves http until the process receives SIGINT or SIGTERM.
+// The scheduler runs while the server is serving.
+func (s *server) run() error {
+	srv := &http.Server{Addr: ":" + s.port, Handler: s.router}
+
+	if s.scheduler != nil {
+		s.scheduler.Start()
+		defer s.scheduler.Stop()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	select {
+	case err := <-errCh:
+		return err
+	case sig := <-sigCh:
+		s.logger.Printf("[INFO] %s %s", "shutting down by", sig)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(ctx)
 }
 
 func (s *server) routes() {
 	s.router.HandleFunc("/api/v1/entries", s.handleEntries())
+	for _, e := range encoders {
+		s.router.HandleFunc("/api/v1/entries."+e.format, s.handleEntries())
+	}
+	s.router.Handle("/debug/vars", expvar.Handler())
 	s.router.HandleFunc("/", s.handleRoot())
 }
 
+// publishMetrics publishes the status of every source including the rate limits to expvar
+func (s *server) publishMetrics() {
+	expvar.Publish("sources", expvar.Func(func() interface{} {
+		return newSourceStatuses(s.blogService.Sources())
+	}))
+}
+
 func (s *server) handleRoot() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		http.Redirect(w, r, "/api/v1/entries", http.StatusMovedPermanently)
@@ -67,34 +180,109 @@ func (s *server) handleRoot() http.HandlerFunc {
 
 func (s *server) handleEntries() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cacheKey := GenerateCacheKey(r.URL.String(), "")
-		var entries []structs.Entry
-		if cache := s.cache.Get(cacheKey); cache != nil {
-			entries = cache
-		} else {
-			s.logger.Printf("[INFO] %s %s %s %s", r.Method, r.URL.Host, r.URL.Path, "cache miss")
-			e, err := s.blogService.Fetch()
-			if err != nil {
-				return
+		enc, err := selectEncoder(r)
+		if err != nil {
+			s.handleError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		flt, err := parseFilter(r.URL.Query())
+		if err != nil {
+			s.handleError(w, r, http.StatusBadRequest, err)
+			return
+		}
+		pq, err := parsePageQuery(r.URL.Query())
+		if err != nil {
+			s.handleError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		snap, err := s.timeline(r.Context())
+		if r.Context().Err() != nil {
+			return
+		}
+		if err != nil {
+			status := http.StatusBadGateway
+			if err == errNoSources {
+				status = http.StatusServiceUnavailable
+			} else if e, ok := err.(*upstreamError); ok && e.timeout() {
+				status = http.StatusGatewayTimeout
 			}
-			s.cache.Set(cacheKey, e, defaultCacheExpiration)
-			entries = e
+			s.handleError(w, r, status, err)
+			return
 		}
+		entries := flt.apply(snap.entries)
+		page, next, prev := paginate(entries, pq)
 
-		sort.Slice(entries, func(j, i int) bool {
-			return entries[i].CreatedAt.Before(entries[j].CreatedAt)
-		})
+		title := s.config.feedTitle
+		if title == "" {
+			title = defaultFeedTitle
+		}
+		self := requestURL(r)
+		lastModified := snap.lastModified()
+		f := &feed{
+			self:         self,
+			title:        title,
+			link:         s.config.originURL,
+			entries:      page,
+			total:        len(entries),
+			next:         pageURL(self, next),
+			prev:         pageURL(self, prev),
+			lastModified: lastModified,
+			sources:      snap.sources,
+		}
+
+		etag, err := strongETag(enc, f)
+		if err != nil {
+			s.handleError(w, r, http.StatusInternalServerError, err)
+			return
+		}
 
-		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		w.Header().Set("Access-Control-Allow-Origin", s.config.originURL)
-		var res entriesResponse
-		res.Entries = entries
-		if err := json.NewEncoder(w).Encode(res); err != nil {
+		w.Header().Add("Vary", "Accept")
+		w.Header().Set("ETag", etag)
+		if !lastModified.IsZero() {
+			w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+		}
+		w.Header().Set("Cache-Control", cacheControl(snap.expiration, time.Now()))
+		if notModified(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		var body bytes.Buffer
+		if err := enc.encode(&body, f); err != nil {
+			s.handleError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+		w.Header().Set("Content-Type", enc.mediaType+"; charset=utf-8")
+		if f.next != "" {
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="next"`, f.next))
+		}
+		if f.prev != "" {
+			w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="prev"`, f.prev))
+		}
+		if _, err := body.WriteTo(w); err != nil {
 			s.logger.Printf("[ERROR] %s %s %s %s", r.Method, r.URL.Host, r.URL.Path, err.Error())
 		}
 	}
 }
 
+// handleError logs err and responds it in json with status
+func (s *server) handleError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	s.logger.Printf("[ERROR] %s %s %d %s", r.Method, r.URL.Path, status, err.Error())
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Access-Control-Allow-Origin", s.config.originURL)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(&errorResponse{
+		Status:  status,
+		Error:   http.StatusText(status),
+		Message: err.Error(),
+		Sources: newSourceStatuses(s.blogService.Sources()),
+	})
+}
+
 func main() {
 	var port string
 	if port = os.Getenv("LISTEN_PORT"); port == "" {
@@ -110,31 +298,95 @@ func main() {
 		log.Fatal("Invalid scheme in origin url")
 	}
 
-	qiitaID := os.Getenv("QIITA_ID")
-	hatenaID := os.Getenv("HATENA_ID")
-	hatenaBlogID := os.Getenv("HATENA_BLOG_ID")
-	hatenaBlogAPIKey := os.Getenv("HATENA_BLOG_API_KEY")
+	fetchTimeout := defaultFetchTimeout
+	if v := os.Getenv("FETCH_TIMEOUT"); v != "" {
+		if fetchTimeout, err = time.ParseDuration(v); err != nil {
+			log.Fatal("Invalid fetch timeout")
+		}
+	}
+
+	maxStale := defaultMaxStale
+	if v := os.Getenv("MAX_STALE"); v != "" {
+		if maxStale, err = time.ParseDuration(v); err != nil {
+			log.Fatal("Invalid max stale")
+		}
+	}
+
+	cacheMaxEntries := defaultCacheMaxEntries
+	if v := os.Getenv("CACHE_MAX_ENTRIES"); v != "" {
+		if cacheMaxEntries, err = strconv.Atoi(v); err != nil {
+			log.Fatal("Invalid cache max entries")
+		}
+	}
+
+	cacheCleanupInterval := defaultCacheCleanupInterval
+	if v := os.Getenv("CACHE_CLEANUP_INTERVAL"); v != "" {
+		if cacheCleanupInterval, err = time.ParseDuration(v); err != nil {
+			log.Fatal("Invalid cache cleanup interval")
+		}
+	}
+
+	logger := log.New(os.Stdout, "", log.Lshortfile)
+
+	var cache storage
+	switch os.Getenv("CACHE_STORAGE") {
+	case "", "memory":
+		cache = newMemStorage(maxStale, cacheMaxEntries, cacheCleanupInterval)
+	case "file":
+		dir := os.Getenv("CACHE_DIR")
+		if dir == "" {
+			dir = defaultCacheDir
+		}
+		fs, err := newFileStorage(dir, maxStale, logger)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cache = newReadThroughStorage(fs, maxStale, 0)
+	case "redis":
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = defaultRedisURL
+		}
+		client, err := redis.NewClient(redisURL, redis.DefaultTimeout)
+		if err != nil {
+			log.Fatal(err)
+		}
+		cache = newReadThroughStorage(newRedisStorage(client, maxStale, logger), maxStale, redisLocalTTL)
+	default:
+		log.Fatal("Invalid cache storage")
+	}
+
+	refreshInterval := defaultRefreshInterval
+	if v := os.Getenv("REFRESH_INTERVAL"); v != "" {
+		if refreshInterval, err = time.ParseDuration(v); err != nil {
+			log.Fatal("Invalid refresh interval")
+		}
+	}
 
 	app := server{
 		router: http.NewServeMux(),
 		port:   port,
-		logger: log.New(os.Stdout, "", log.Lshortfile),
+		logger: logger,
 		config: config{
-			originURL:    originURL,
-			qiitaID:      qiitaID,
-			hatenaID:     hatenaID,
-			hatenaBlogID: hatenaBlogID,
-			hatenaAPIKey: hatenaBlogAPIKey,
-		},
-		cache: &memStorage{
-			items: make(map[string]item),
-			mu:    &sync.RWMutex{},
-		},
-		blogService: &blogservice.BlogService{
-			FetchFunc: []func() ([]structs.Entry, error){},
+			originURL:       originURL,
+			feedTitle:       os.Getenv("FEED_TITLE"),
+			fetchTimeout:    fetchTimeout,
+			refreshInterval: refreshInterval,
+			services:        os.Getenv,
 		},
+		cache:       cache,
+		blogService: &blogservice.BlogService{},
+	}
+	if err := app.blogservices(); err != nil {
+		log.Fatal(err)
 	}
-	app.blogservices()
 	app.routes()
-	log.Fatal(http.ListenAndServe(":"+app.port, app.router))
+	app.publishMetrics()
+	err = app.run()
+	// background revalidations still write to the cache
+	app.flight.drain()
+	app.cache.Close()
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
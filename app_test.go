@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"reflect"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -17,6 +23,20 @@ import (
 	"github.com/shiimaxx/blog-aggregator/structs"
 )
 
+type stubProvider struct {
+	name    string
+	entries []structs.Entry
+	err     error
+	calls   int32
+}
+
+func (p *stubProvider) Name() string { return p.name }
+func (p *stubProvider) Kind() string { return "stub" }
+func (p *stubProvider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	atomic.AddInt32(&p.calls, 1)
+	return p.entries, p.err
+}
+
 func TestHandleRoot(t *testing.T) {
 	req, err := http.NewRequest("GET", "/", nil)
 	if err != nil {
@@ -49,15 +69,15 @@ func TestHandleEntries_CacheHit(t *testing.T) {
 
 	s := server{
 		logger: log.New(os.Stdout, "", log.Lshortfile),
-		config: config{
-			qiitaID: "testuser",
-		},
 		cache: &memStorage{
 			items: make(map[string]item),
 			mu:    &sync.RWMutex{},
 		},
+		blogService: &blogservice.BlogService{},
 	}
-	cacheKey := GenerateCacheKey("/api/v1/entries", "")
+	p := &stubProvider{name: "stub:testuser"}
+	s.blogService.Add(p)
+	cacheKey := GenerateCacheKey("stub:testuser", "stub")
 	dummyData := []structs.Entry{
 		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
 		{Title: "b", URL: "https://example.com/b", CreatedAt: now.Add(1 * time.Hour)},
@@ -84,7 +104,12 @@ func TestHandleEntries_CacheHit(t *testing.T) {
 	if got, want := e.Entries, dummyData; reflect.DeepEqual(got, want) {
 		t.Fatalf("got %v, want %v", got, want)
 	}
-
+	if got, want := len(e.Entries), len(dummyData); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := p.calls, int32(0); got != want {
+		t.Fatalf("got %v fetches, want %v", got, want)
+	}
 }
 
 func TestHandleEntries_CacheMiss(t *testing.T) {
@@ -97,16 +122,11 @@ func TestHandleEntries_CacheMiss(t *testing.T) {
 
 	s := server{
 		logger: log.New(os.Stdout, "", log.Lshortfile),
-		config: config{
-			qiitaID: "testuser",
-		},
 		cache: &memStorage{
 			items: make(map[string]item),
 			mu:    &sync.RWMutex{},
 		},
-		blogService: &blogservice.BlogService{
-			FetchFunc: []func() ([]structs.Entry, error){},
-		},
+		blogService: &blogservice.BlogService{},
 	}
 
 	dummyData := []structs.Entry{
@@ -117,10 +137,7 @@ func TestHandleEntries_CacheMiss(t *testing.T) {
 		{Title: "e", URL: "https://example.com/e", CreatedAt: now.Add(4 * time.Hour)},
 		{Title: "f", URL: "https://example.com/f", CreatedAt: now.Add(5 * time.Hour)},
 	}
-	mockFunc := func() ([]structs.Entry, error) {
-		return dummyData, nil
-	}
-	s.blogService.Add(mockFunc)
+	s.blogService.Add(&stubProvider{name: "stub:testuser", entries: dummyData})
 
 	handler := s.handleEntries()
 
@@ -139,3 +156,618 @@ func TestHandleEntries_CacheMiss(t *testing.T) {
 		t.Fatalf("got %v, want %v", got, want)
 	}
 }
+
+func TestHandleEntries_PartialFailure(t *testing.T) {
+	req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+	if err != nil {
+		t.Fatal("NewRequest failed: ", err.Error())
+	}
+
+	rec := httptest.NewRecorder()
+
+	s := server{
+		logger: log.New(os.Stdout, "", log.Lshortfile),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+
+	dummyData := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+		{Title: "b", URL: "https://example.com/b", CreatedAt: now.Add(1 * time.Hour)},
+	}
+	s.blogService.Add(&stubProvider{name: "stub:ok", entries: dummyData})
+	s.blogService.Add(&stubProvider{name: "stub:ng", err: errors.New("service unavailable")})
+
+	handler := s.handleEntries()
+
+	handler.ServeHTTP(rec, req)
+
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	var e entriesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+		t.Fatal("json Decode failed: ", err)
+	}
+	if got, want := len(e.Entries), len(dummyData); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	want := []sourceStatus{
+		{Name: "stub:ok", Kind: "stub", Status: "ok", Count: 2, Circuit: "closed"},
+		{Name: "stub:ng", Kind: "stub", Status: "error", Error: "service unavailable", Circuit: "closed"},
+	}
+	if got := e.Sources; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestHandleEntries_SourcesFromCache(t *testing.T) {
+	cached := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+		{Title: "b", URL: "https://example.com/b", CreatedAt: now.Add(1 * time.Hour)},
+	}
+
+	// steps are "set" to cache entries as another process does, or "fail" to fail a fetch in this process
+	cases := []struct {
+		name  string
+		steps []string
+		want  sourceStatus
+	}{
+		{name: "cached by another process", steps: []string{"set"}, want: sourceStatus{Name: "stub:testuser", Kind: "stub", Status: "ok", Count: 2, Circuit: "closed"}},
+		{name: "cached after failure", steps: []string{"fail", "set"}, want: sourceStatus{Name: "stub:testuser", Kind: "stub", Status: "ok", Count: 2, Circuit: "closed"}},
+		{name: "failed after cached", steps: []string{"set", "fail"}, want: sourceStatus{Name: "stub:testuser", Kind: "stub", Status: "error", Error: "service unavailable", Count: 2, Circuit: "closed"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := server{
+				logger: log.New(ioutil.Discard, "", 0),
+				cache: &memStorage{
+					items: make(map[string]item),
+					mu:    &sync.RWMutex{},
+				},
+				blogService: &blogservice.BlogService{},
+			}
+			p := &stubProvider{name: "stub:testuser", err: errors.New("service unavailable")}
+			s.blogService.Add(p)
+
+			for _, step := range tc.steps {
+				switch step {
+				case "set":
+					s.cache.Set(sourceCacheKey(p), cached, defaultCacheExpiration)
+				case "fail":
+					s.blogService.FetchSource(context.Background(), p)
+				}
+				time.Sleep(time.Millisecond)
+			}
+
+			req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+			if err != nil {
+				t.Fatal("NewRequest failed: ", err.Error())
+			}
+			rec := httptest.NewRecorder()
+			s.handleEntries().ServeHTTP(rec, req)
+
+			var e entriesResponse
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+				t.Fatal("json Decode failed: ", err)
+			}
+			if got, want := len(e.Entries), len(cached); got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got := e.Sources; !reflect.DeepEqual(got, []sourceStatus{tc.want}) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestHandleEntries_CachePerSource(t *testing.T) {
+	s := server{
+		logger: log.New(os.Stdout, "", log.Lshortfile),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	ok := &stubProvider{name: "stub:ok", entries: []structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}}}
+	empty := &stubProvider{name: "stub:empty"}
+	ng := &stubProvider{name: "stub:ng", err: errors.New("service unavailable")}
+	s.blogService.Add(ok)
+	s.blogService.Add(empty)
+	s.blogService.Add(ng)
+
+	handler := s.handleEntries()
+
+	for _, url := range []string{"/api/v1/entries", "/api/v1/entries.atom", "/api/v1/entries?limit=1&q=a"} {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal("NewRequest failed: ", err.Error())
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if got, want := ok.calls, int32(1); got != want {
+		t.Fatalf("got %v fetches of ok, want %v", got, want)
+	}
+	if got, want := empty.calls, int32(1); got != want {
+		t.Fatalf("got %v fetches of empty, want %v", got, want)
+	}
+	if got, want := ng.calls, int32(3); got != want {
+		t.Fatalf("got %v fetches of ng, want %v", got, want)
+	}
+}
+
+func TestHandleEntries_StaleWhileRevalidate(t *testing.T) {
+	cases := []struct {
+		name       string
+		expiration time.Duration
+		fetchErr   error
+		// backingOff is whether the provider has backed off by its rate limit before the request
+		backingOff bool
+		wantTitles []string
+		wantState  state
+		wantCalls  int32
+	}{
+		{name: "stale is served and revalidated", expiration: -30 * time.Second, wantTitles: []string{"old"}, wantState: fresh, wantCalls: 1},
+		{name: "stale is served on error", expiration: -30 * time.Second, fetchErr: errors.New("service unavailable"), wantTitles: []string{"old"}, wantState: stale, wantCalls: 1},
+		{name: "expired beyond max stale is fetched", expiration: -90 * time.Second, wantTitles: []string{"new"}, wantState: fresh, wantCalls: 1},
+		{name: "fresh is not revalidated", expiration: 30 * time.Second, wantTitles: []string{"old"}, wantState: fresh, wantCalls: 0},
+		{name: "stale is not revalidated while backing off", expiration: -30 * time.Second, fetchErr: &blogservice.RateLimitError{RateLimit: blogservice.RateLimit{Reset: time.Now().Add(time.Hour)}}, backingOff: true, wantTitles: []string{"old"}, wantState: stale, wantCalls: 1},
+		{name: "stale is revalidated after the rate limit reset", expiration: -30 * time.Second, fetchErr: &blogservice.RateLimitError{RateLimit: blogservice.RateLimit{Reset: time.Now().Add(-time.Second)}}, backingOff: true, wantTitles: []string{"old"}, wantState: stale, wantCalls: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := server{
+				logger: log.New(os.Stdout, "", log.Lshortfile),
+				cache: &memStorage{
+					items:    make(map[string]item),
+					mu:       &sync.RWMutex{},
+					maxStale: 60 * time.Second,
+				},
+				blogService: &blogservice.BlogService{},
+			}
+			p := &stubProvider{
+				name:    "stub:testuser",
+				entries: []structs.Entry{{Title: "new", URL: "https://example.com/new", CreatedAt: now}},
+				err:     tc.fetchErr,
+			}
+			s.blogService.Add(p)
+			s.cache.Set(sourceCacheKey(p), []structs.Entry{{Title: "old", URL: "https://example.com/old", CreatedAt: now}}, tc.expiration)
+			if tc.backingOff {
+				s.blogService.FetchSource(context.Background(), p)
+			}
+
+			req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+			if err != nil {
+				t.Fatal("NewRequest failed: ", err.Error())
+			}
+			rec := httptest.NewRecorder()
+			s.handleEntries().ServeHTTP(rec, req)
+			s.flight.drain()
+
+			var e entriesResponse
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+				t.Fatal("json Decode failed: ", err)
+			}
+			var titles []string
+			for _, entry := range e.Entries {
+				titles = append(titles, entry.Title)
+			}
+			if !reflect.DeepEqual(titles, tc.wantTitles) {
+				t.Fatalf("got %v, want %v", titles, tc.wantTitles)
+			}
+			if _, _, st := s.cache.Get(sourceCacheKey(p)); st != tc.wantState {
+				t.Fatalf("got %v, want %v", st, tc.wantState)
+			}
+			if got := atomic.LoadInt32(&p.calls); got != tc.wantCalls {
+				t.Fatalf("got %v fetches, want %v", got, tc.wantCalls)
+			}
+		})
+	}
+}
+
+func TestHandleEntries_Concurrent(t *testing.T) {
+	s := server{
+		logger: log.New(ioutil.Discard, "", 0),
+		cache: &memStorage{
+			items:    make(map[string]item),
+			mu:       &sync.RWMutex{},
+			maxStale: 60 * time.Second,
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	var providers []*stubProvider
+	for i := 0; i < 3; i++ {
+		p := &stubProvider{name: fmt.Sprintf("stub:%d", i)}
+		// entries are served unsorted, oldest first
+		for j := 0; j < 20; j++ {
+			p.entries = append(p.entries, structs.Entry{
+				ID:        fmt.Sprintf("stub:%d:%02d", i, j),
+				Title:     fmt.Sprintf("%d-%02d", i, j),
+				Tags:      []string{fmt.Sprintf("tag%d", j%3)},
+				CreatedAt: now.Add(time.Duration(j*3+i) * time.Minute),
+			})
+		}
+		providers = append(providers, p)
+		s.blogService.Add(p)
+	}
+	handler := s.handleEntries()
+
+	urls := []string{
+		"/api/v1/entries",
+		"/api/v1/entries?limit=7",
+		"/api/v1/entries?limit=5&offset=10",
+		"/api/v1/entries?tag=tag1",
+		"/api/v1/entries?q=1-",
+		"/api/v1/entries.atom",
+		"/api/v1/entries.rss?limit=3",
+	}
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, 200)
+	for i := 0; i < 100; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			url := urls[i%len(urls)]
+			req, _ := http.NewRequest("GET", url, nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				errCh <- fmt.Errorf("%s: got %v, want %v", url, rec.Code, http.StatusOK)
+				return
+			}
+			if i%len(urls) > 4 {
+				return
+			}
+			var e entriesResponse
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+				errCh <- fmt.Errorf("%s: %s", url, err)
+				return
+			}
+			for j := 1; j < len(e.Entries); j++ {
+				if newer(e.Entries[j], e.Entries[j-1]) {
+					errCh <- fmt.Errorf("%s: got %v before %v", url, e.Entries[j-1].Title, e.Entries[j].Title)
+					return
+				}
+			}
+		}()
+		// refreshes replace the cached entries while they are served
+		if i%10 == 0 {
+			p := providers[i%len(providers)]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.refresh(context.Background(), p)
+			}()
+		}
+	}
+	wg.Wait()
+	close(errCh)
+
+	for err := range errCh {
+		t.Error(err)
+	}
+	for _, p := range providers {
+		if got, want := p.entries[0].Title[2:], "00"; got != want {
+			t.Fatalf("got %v, want %v: provider entries are modified", got, want)
+		}
+	}
+}
+
+// blockingProvider blocks until the fetch is canceled
+type blockingProvider struct {
+	name string
+}
+
+func (p *blockingProvider) Name() string { return p.name }
+func (p *blockingProvider) Kind() string { return "stub" }
+func (p *blockingProvider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestHandleEntries_Error(t *testing.T) {
+	cases := []struct {
+		name       string
+		url        string
+		providers  []blogservice.Provider
+		wantStatus int
+		wantSource bool
+	}{
+		{
+			name:       "no sources",
+			url:        "/api/v1/entries",
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "all upstreams fail",
+			url:  "/api/v1/entries",
+			providers: []blogservice.Provider{
+				&stubProvider{name: "stub:a", err: errors.New("service unavailable")},
+				&blockingProvider{name: "stub:b"},
+			},
+			wantStatus: http.StatusBadGateway,
+			wantSource: true,
+		},
+		{
+			name: "all upstreams time out",
+			url:  "/api/v1/entries.atom",
+			providers: []blogservice.Provider{
+				&blockingProvider{name: "stub:a"},
+				&blockingProvider{name: "stub:b"},
+			},
+			wantStatus: http.StatusGatewayTimeout,
+			wantSource: true,
+		},
+		{
+			name: "invalid query",
+			url:  "/api/v1/entries?limit=a",
+			providers: []blogservice.Provider{
+				&stubProvider{name: "stub:a"},
+			},
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			s := server{
+				logger: log.New(&logs, "", 0),
+				cache: &memStorage{
+					items: make(map[string]item),
+					mu:    &sync.RWMutex{},
+				},
+				blogService: &blogservice.BlogService{Timeout: 50 * time.Millisecond},
+			}
+			for _, p := range tc.providers {
+				s.blogService.Add(p)
+			}
+
+			req, err := http.NewRequest("GET", tc.url, nil)
+			if err != nil {
+				t.Fatal("NewRequest failed: ", err.Error())
+			}
+			rec := httptest.NewRecorder()
+			s.handleEntries().ServeHTTP(rec, req)
+
+			if got, want := rec.Code, tc.wantStatus; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := rec.HeaderMap.Get("Content-Type"), "application/json; charset=utf-8"; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			var e errorResponse
+			if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+				t.Fatal("json Decode failed: ", err)
+			}
+			if got, want := e.Status, tc.wantStatus; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := e.Error, http.StatusText(tc.wantStatus); got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if e.Message == "" {
+				t.Fatal("got empty message")
+			}
+			if tc.wantSource {
+				if got, want := len(e.Sources), len(tc.providers); got != want {
+					t.Fatalf("got %v sources, want %v", got, want)
+				}
+				for _, src := range e.Sources {
+					if src.Status != "error" {
+						t.Fatalf("got %v, want error", src)
+					}
+				}
+			}
+			if got, want := logs.String(), "[ERROR] GET /api/v1/entries"; !strings.Contains(got, want) {
+				t.Fatalf("got %q, want %q logged", got, want)
+			}
+		})
+	}
+}
+
+func TestHandleEntries_NotModified(t *testing.T) {
+	s := server{
+		logger: log.New(ioutil.Discard, "", 0),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	p := &stubProvider{name: "stub:testuser"}
+	s.blogService.Add(p)
+	updated := time.Date(2018, 11, 28, 10, 0, 0, 0, time.UTC)
+	s.cache.Set(sourceCacheKey(p), []structs.Entry{
+		{ID: "stub:2", Title: "b", CreatedAt: updated.Add(-time.Hour), UpdatedAt: updated},
+		{ID: "stub:1", Title: "a", CreatedAt: updated.Add(-2 * time.Hour)},
+	}, 90*time.Second)
+	handler := s.handleEntries()
+
+	get := func(url string, header map[string]string) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			t.Fatal("NewRequest failed: ", err.Error())
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/api/v1/entries", nil)
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	etag := rec.HeaderMap.Get("ETag")
+	if !strings.HasPrefix(etag, `"`) || !strings.HasSuffix(etag, `"`) {
+		t.Fatalf("got %v, want a strong etag", etag)
+	}
+	if got, want := rec.HeaderMap.Get("Last-Modified"), "Wed, 28 Nov 2018 10:00:00 GMT"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	var maxAge int
+	if _, err := fmt.Sscanf(rec.HeaderMap.Get("Cache-Control"), "public, max-age=%d", &maxAge); err != nil || maxAge < 80 || maxAge > 90 {
+		t.Fatalf("got %v, want max-age about 90", rec.HeaderMap.Get("Cache-Control"))
+	}
+
+	cases := []struct {
+		name       string
+		url        string
+		header     map[string]string
+		wantStatus int
+	}{
+		{name: "same etag", url: "/api/v1/entries", header: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusNotModified},
+		{name: "other etag", url: "/api/v1/entries", header: map[string]string{"If-None-Match": `"other"`}, wantStatus: http.StatusOK},
+		{name: "other representation", url: "/api/v1/entries.atom", header: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusOK},
+		{name: "other page", url: "/api/v1/entries?limit=1", header: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusOK},
+		// the newest entry is as old as before when an entry is deleted, so that the date alone is not trusted
+		{name: "not modified since", url: "/api/v1/entries", header: map[string]string{"If-Modified-Since": "Wed, 28 Nov 2018 10:00:00 GMT"}, wantStatus: http.StatusOK},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := get(tc.url, tc.header)
+			if got, want := rec.Code, tc.wantStatus; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if tc.wantStatus == http.StatusNotModified {
+				if got := rec.Body.Len(); got != 0 {
+					t.Fatalf("got %v bytes of body, want empty", got)
+				}
+				if got, want := rec.HeaderMap.Get("ETag"), etag; got != want {
+					t.Fatalf("got %v, want %v", got, want)
+				}
+			}
+		})
+	}
+
+	// the status of the source changes, but the page does not
+	p.err = errRefresh
+	s.blogService.FetchSource(context.Background(), p)
+	if got, want := get("/api/v1/entries", map[string]string{"If-None-Match": etag}).Code, http.StatusNotModified; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	// an empty page is as old as the timeline
+	rec = get("/api/v1/entries.atom?q=nothing", nil)
+	if got, want := rec.Body.String(), "<updated>2018-11-28T10:00:00+00:00</updated>"; !strings.Contains(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestHandleEntries_CircuitOpen(t *testing.T) {
+	var logs bytes.Buffer
+	s := server{
+		logger: log.New(&logs, "", 0),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	ok := &stubProvider{name: "stub:ok", entries: []structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}}}
+	ng := &stubProvider{name: "stub:ng", err: errors.New("service unavailable")}
+	s.blogService.Add(ok)
+	s.blogService.Add(ng)
+	handler := s.handleEntries()
+
+	threshold := blogservice.DefaultBreakerPolicy.Threshold
+	var e entriesResponse
+	for i := 0; i < threshold+2; i++ {
+		req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+		if err != nil {
+			t.Fatal("NewRequest failed: ", err.Error())
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		e = entriesResponse{}
+		if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+			t.Fatal("json Decode failed: ", err)
+		}
+	}
+
+	if got, want := atomic.LoadInt32(&ng.calls), int32(threshold); got != want {
+		t.Fatalf("got %v fetches, want %v", got, want)
+	}
+	want := sourceStatus{Name: "stub:ng", Kind: "stub", Status: "error", Error: "service unavailable", Circuit: "open"}
+	if got := e.Sources[1]; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := strings.Count(logs.String(), "[ERROR] stub:ng"), threshold; got != want {
+		t.Fatalf("got %v errors logged, want %v", got, want)
+	}
+}
+
+type rateLimitedProvider struct {
+	stubProvider
+	rateLimit blogservice.RateLimit
+}
+
+func (p *rateLimitedProvider) RateLimit() (blogservice.RateLimit, bool) { return p.rateLimit, true }
+
+func TestHandleEntries_RateLimited(t *testing.T) {
+	var logs bytes.Buffer
+	s := server{
+		logger: log.New(&logs, "", 0),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	reset := now.Add(time.Hour).UTC()
+	rl := blogservice.RateLimit{Limit: 60, Remaining: 5, Reset: reset}
+	ok := &stubProvider{name: "stub:ok", entries: []structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}}}
+	limited := &rateLimitedProvider{
+		stubProvider: stubProvider{name: "stub:limited", err: &blogservice.RateLimitError{RateLimit: rl}},
+		rateLimit:    rl,
+	}
+	s.blogService.Add(ok)
+	s.blogService.Add(limited)
+
+	threshold := blogservice.DefaultBreakerPolicy.Threshold
+	var e entriesResponse
+	for i := 0; i < threshold+1; i++ {
+		req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+		if err != nil {
+			t.Fatal("NewRequest failed: ", err.Error())
+		}
+		rec := httptest.NewRecorder()
+		s.handleEntries().ServeHTTP(rec, req)
+		e = entriesResponse{}
+		if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+			t.Fatal("json Decode failed: ", err)
+		}
+	}
+
+	got := e.Sources[1]
+	if got.Circuit != "closed" {
+		t.Fatalf("got %v, want %v", got.Circuit, "closed")
+	}
+	if got.RateLimit == nil {
+		t.Fatal("got no rate limit")
+	}
+	if want := (rateLimitStatus{Limit: 60, Remaining: 5, Reset: reset}); *got.RateLimit != want {
+		t.Fatalf("got %v, want %v", *got.RateLimit, want)
+	}
+	if e.Sources[0].RateLimit != nil {
+		t.Fatalf("got %v, want nil", e.Sources[0].RateLimit)
+	}
+	// backed off fetches send no request, so they are neither errors nor news of the rate limit
+	if got := logs.String(); strings.Contains(got, "[ERROR] stub:limited") || strings.Contains(got, "stub:limited rate limit") {
+		t.Fatalf("got %q, want backed off fetches not logged", got)
+	}
+}
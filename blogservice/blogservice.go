@@ -1,44 +1,197 @@
 package blogservice
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"sync"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/shiimaxx/blog-aggregator/structs"
-	"golang.org/x/sync/errgroup"
 )
 
-var HTTPClient = http.DefaultClient
+// HTTPClient sends requests to blog services.
+// Its transport bounds connecting and waiting for response headers, and the fetch timeout bounds the rest.
+var HTTPClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	},
+}
+
+// Provider is a source of blog entries
+type Provider interface {
+	// Name returns the name which identifies the provider instance, e.g. "qiita:shiimaxx"
+	Name() string
+	// Kind returns the blog service kind of the provider, e.g. "qiita"
+	Kind() string
+	// Fetch fetches entries from the blog service
+	Fetch(ctx context.Context) ([]structs.Entry, error)
+}
+
+// Source is the status of the latest fetch from a provider
+type Source struct {
+	Name      string
+	Kind      string
+	Count     int
+	Err       error
+	FetchedAt time.Time
+	// Breaker is the state of the circuit breaker of the provider
+	Breaker BreakerState
+	// RateLimit is the request budget of the provider, or nil if it is unknown
+	RateLimit *RateLimit
+}
+
+// TimeoutError is returned by FetchSource when the provider does not respond within the timeout
+type TimeoutError struct {
+	Err error
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Err.Error()
+}
+
+// Cause returns the error returned by the provider
+func (e *TimeoutError) Cause() error {
+	return e.Err
+}
+
+// IsTimeout reports whether err is caused by a timeout of fetching
+func IsTimeout(err error) bool {
+	if _, ok := err.(*TimeoutError); ok {
+		return true
+	}
+	return errors.Cause(err) == context.DeadlineExceeded
+}
 
 type BlogService struct {
-	FetchFunc []func() ([]structs.Entry, error)
+	Providers []Provider
+	// Timeout bounds a fetch from a provider which has no timeout of its own. Zero means no timeout.
+	Timeout time.Duration
+
+	mu       sync.RWMutex
+	sources  map[string]Source
+	breakers map[string]*Breaker
 }
 
-func (b *BlogService) Add(fn func() ([]structs.Entry, error)) {
-	b.FetchFunc = append(b.FetchFunc, fn)
+func (b *BlogService) Add(p Provider) {
+	b.Providers = append(b.Providers, p)
 }
 
-func (b *BlogService) Fetch() ([]structs.Entry, error) {
-	eg := errgroup.Group{}
-	var entries []structs.Entry
-	var mu sync.Mutex
-	for _, fn := range b.FetchFunc {
-		fn := fn
-		eg.Go(func() error {
-			e, err := fn()
-			if err != nil {
-				return err
-			}
-			mu.Lock()
-			entries = append(entries, e...)
-			mu.Unlock()
-			return nil
-		})
+// FetchSource fetches entries from p within the timeout of p, and records the status of p.
+// It returns ErrCircuitOpen without fetching while the circuit breaker of p is open.
+// A fetch canceled by ctx is not recorded.
+func (b *BlogService) FetchSource(ctx context.Context, p Provider) ([]structs.Entry, error) {
+	br := b.breaker(p)
+	if err := br.Allow(); err != nil {
+		return nil, err
 	}
 
-	if err := eg.Wait(); err != nil {
+	timeout := b.Timeout
+	if c, ok := p.(Configurable); ok && c.Options().Timeout > 0 {
+		timeout = c.Options().Timeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	e, err := p.Fetch(ctx)
+	if err != nil && ctx.Err() == context.Canceled {
+		// the caller gave up, which tells nothing about p, so that only the probe of the breaker is released
+		br.Record(context.Canceled)
 		return nil, err
 	}
+	if err != nil && ctx.Err() == context.DeadlineExceeded {
+		err = &TimeoutError{Err: err}
+	}
+	br.Record(err)
+	src := Source{
+		Name:      p.Name(),
+		Kind:      p.Kind(),
+		Count:     len(e),
+		Err:       err,
+		FetchedAt: time.Now(),
+	}
+	if err != nil {
+		src.Count = 0
+	}
+	if l, ok := p.(RateLimiter); ok {
+		if rl, ok := l.RateLimit(); ok {
+			src.RateLimit = &rl
+		}
+	}
+	b.record(src)
+
+	return e, err
+}
+
+// Sources returns the status of the latest fetch from every provider.
+// FetchedAt is zero for a provider which has never been fetched.
+func (b *BlogService) Sources() []Source {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	sources := make([]Source, len(b.Providers))
+	for i, p := range b.Providers {
+		s, ok := b.sources[p.Name()]
+		if !ok {
+			s = Source{Name: p.Name(), Kind: p.Kind()}
+		}
+		if br, ok := b.breakers[p.Name()]; ok {
+			s.Breaker = br.State()
+		}
+		sources[i] = s
+	}
+	return sources
+}
+
+// BackingOff reports whether the latest fetch from p was backed off by its rate limit which has not been reset yet,
+// so that fetching p again would be backed off too
+func (b *BlogService) BackingOff(p Provider) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 
-	return entries, nil
+	e, ok := errors.Cause(b.sources[p.Name()].Err).(*RateLimitError)
+	return ok && time.Now().Before(e.RateLimit.Reset)
+}
+
+// breaker returns the circuit breaker of p
+func (b *BlogService) breaker(p Provider) *Breaker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if br, ok := b.breakers[p.Name()]; ok {
+		return br
+	}
+	policy := DefaultBreakerPolicy
+	if c, ok := p.(Configurable); ok {
+		policy = c.Options().Breaker
+	}
+	if b.breakers == nil {
+		b.breakers = make(map[string]*Breaker)
+	}
+	br := NewBreaker(policy)
+	b.breakers[p.Name()] = br
+	return br
+}
+
+func (b *BlogService) record(src Source) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.sources == nil {
+		b.sources = make(map[string]Source)
+	}
+	b.sources[src.Name] = src
 }
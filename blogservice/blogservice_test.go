@@ -0,0 +1,125 @@
+package blogservice
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+type slowProvider struct {
+	name    string
+	delay   time.Duration
+	entries []structs.Entry
+	opts    Options
+}
+
+func (p *slowProvider) Name() string     { return p.name }
+func (p *slowProvider) Kind() string     { return "slow" }
+func (p *slowProvider) Options() Options { return p.opts }
+func (p *slowProvider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	select {
+	case <-time.After(p.delay):
+		return p.entries, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestBlogService_FetchSourceTimeout(t *testing.T) {
+	cases := []struct {
+		name        string
+		provider    *slowProvider
+		wantTimeout bool
+	}{
+		{name: "fast", provider: &slowProvider{name: "fast", entries: []structs.Entry{{Title: "a"}}}},
+		{name: "slow", provider: &slowProvider{name: "slow", delay: time.Second, entries: []structs.Entry{{Title: "b"}}}, wantTimeout: true},
+		{name: "own timeout", provider: &slowProvider{name: "patient", delay: 100 * time.Millisecond, entries: []structs.Entry{{Title: "c"}}, opts: Options{Timeout: time.Second}}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := BlogService{Timeout: 50 * time.Millisecond}
+			b.Add(tc.provider)
+
+			start := time.Now()
+			e, err := b.FetchSource(context.Background(), tc.provider)
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Fatalf("got elapsed %v; want less than 500ms", elapsed)
+			}
+
+			if !tc.wantTimeout {
+				if err != nil {
+					t.Fatal("FetchSource failed: ", err)
+				}
+				if got, want := len(e), 1; got != want {
+					t.Fatalf("got %v entries; want %v", got, want)
+				}
+				return
+			}
+			if got, want := errors.Cause(err), context.DeadlineExceeded; got != want {
+				t.Fatalf("got %v; want %v", got, want)
+			}
+			if !IsTimeout(err) {
+				t.Fatalf("got %v; want timeout", err)
+			}
+			if got := b.Sources()[0].Err; got != err {
+				t.Fatalf("got %v; want %v", got, err)
+			}
+		})
+	}
+}
+
+func TestBlogService_FetchSourceCanceled(t *testing.T) {
+	b := BlogService{}
+	p := &slowProvider{name: "slow", delay: time.Second}
+	b.Add(p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := b.FetchSource(ctx, p); err != context.Canceled {
+		t.Fatalf("got %v; want %v", err, context.Canceled)
+	}
+}
+
+type errProvider struct {
+	err error
+}
+
+func (p *errProvider) Name() string { return "err" }
+func (p *errProvider) Kind() string { return "test" }
+func (p *errProvider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	return nil, p.err
+}
+
+func TestBlogService_BackingOff(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "succeeded", want: false},
+		{name: "failed", err: errors.New("service unavailable"), want: false},
+		{name: "backed off", err: &RateLimitError{RateLimit: RateLimit{Reset: time.Now().Add(time.Hour)}}, want: true},
+		{name: "backed off wrapped", err: errors.Wrap(&RateLimitError{RateLimit: RateLimit{Reset: time.Now().Add(time.Hour)}}, "page 2"), want: true},
+		{name: "rate limit reset", err: &RateLimitError{RateLimit: RateLimit{Reset: time.Now().Add(-time.Second)}}, want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := BlogService{}
+			p := &errProvider{err: tc.err}
+			b.Add(p)
+			if got := b.BackingOff(p); got {
+				t.Fatalf("got %v before fetching; want false", got)
+			}
+
+			b.FetchSource(context.Background(), p)
+			if got := b.BackingOff(p); got != tc.want {
+				t.Fatalf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package blogservice
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrCircuitOpen is returned by FetchSource when the circuit breaker of the provider is open
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets every fetch through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen short-circuits fetches until the cool-down has passed
+	BreakerOpen
+	// BreakerHalfOpen lets a probe through, which closes the breaker on success and opens it again on failure
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerPolicy is when the circuit breaker of a provider opens and closes
+type BreakerPolicy struct {
+	// Threshold is the number of consecutive failures which opens the breaker. Zero disables the breaker.
+	Threshold int
+	// Cooldown is how long the breaker is open before it lets a probe through
+	Cooldown time.Duration
+}
+
+// DefaultBreakerPolicy is the breaker policy of providers which do not configure one
+var DefaultBreakerPolicy = BreakerPolicy{
+	Threshold: 5,
+	Cooldown:  time.Minute,
+}
+
+// Breaker is a circuit breaker of a provider
+type Breaker struct {
+	policy BreakerPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewBreaker returns a closed Breaker
+func NewBreaker(policy BreakerPolicy) *Breaker {
+	return &Breaker{policy: policy, now: time.Now}
+}
+
+// Allow returns ErrCircuitOpen if a fetch should be short-circuited.
+// Once the cool-down has passed, it lets a probe through and the breaker gets half-open.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.policy.Cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+	default:
+		return nil
+	}
+	b.probing = true
+	return nil
+}
+
+// Record updates the breaker by the result of a fetch allowed by Allow.
+// Fetches canceled by the caller or backed off by the rate limit are neither successes nor failures.
+func (b *Breaker) Record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	switch {
+	case err == nil:
+		b.state = BreakerClosed
+		b.failures = 0
+	case errors.Cause(err) == context.Canceled, IsRateLimited(err):
+	case b.state == BreakerHalfOpen:
+		b.open()
+	default:
+		b.failures++
+		if b.policy.Threshold > 0 && b.failures >= b.policy.Threshold {
+			b.open()
+		}
+	}
+}
+
+// State returns the state of the breaker
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+func (b *Breaker) open() {
+	b.state = BreakerOpen
+	b.openedAt = b.now()
+}
@@ -0,0 +1,102 @@
+package blogservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+func TestBreaker(t *testing.T) {
+	errFetch := errors.New("service unavailable")
+
+	// steps are "allow", "deny", "ok", "fail", "cancel", "limit" or "wait" for the cool-down
+	cases := []struct {
+		name      string
+		steps     []string
+		wantState BreakerState
+	}{
+		{name: "closed", steps: []string{"allow", "fail", "allow", "fail"}, wantState: BreakerClosed},
+		{name: "success resets failures", steps: []string{"allow", "fail", "allow", "fail", "allow", "ok", "allow", "fail", "allow", "fail"}, wantState: BreakerClosed},
+		{name: "open", steps: []string{"allow", "fail", "allow", "fail", "allow", "fail", "deny"}, wantState: BreakerOpen},
+		{name: "half-open", steps: []string{"allow", "fail", "allow", "fail", "allow", "fail", "wait", "allow", "deny"}, wantState: BreakerHalfOpen},
+		{name: "probe succeeds", steps: []string{"allow", "fail", "allow", "fail", "allow", "fail", "wait", "allow", "ok", "allow"}, wantState: BreakerClosed},
+		{name: "probe fails", steps: []string{"allow", "fail", "allow", "fail", "allow", "fail", "wait", "allow", "fail", "deny"}, wantState: BreakerOpen},
+		{name: "probe canceled", steps: []string{"allow", "fail", "allow", "fail", "allow", "fail", "wait", "allow", "cancel", "allow"}, wantState: BreakerHalfOpen},
+		{name: "cancel is not failure", steps: []string{"allow", "fail", "allow", "fail", "allow", "cancel", "allow"}, wantState: BreakerClosed},
+		{name: "rate limit is not failure", steps: []string{"allow", "limit", "allow", "limit", "allow", "limit", "allow"}, wantState: BreakerClosed},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			now := time.Date(2018, 11, 28, 10, 0, 0, 0, time.UTC)
+			b := NewBreaker(BreakerPolicy{Threshold: 3, Cooldown: time.Minute})
+			b.now = func() time.Time { return now }
+
+			for i, step := range tc.steps {
+				switch step {
+				case "allow":
+					if err := b.Allow(); err != nil {
+						t.Fatalf("step %d: got %v; want allowed", i, err)
+					}
+				case "deny":
+					if err := b.Allow(); err != ErrCircuitOpen {
+						t.Fatalf("step %d: got %v; want %v", i, err, ErrCircuitOpen)
+					}
+				case "ok":
+					b.Record(nil)
+				case "fail":
+					b.Record(errFetch)
+				case "cancel":
+					b.Record(context.Canceled)
+				case "limit":
+					b.Record(&RateLimitError{})
+				case "wait":
+					now = now.Add(time.Minute)
+				}
+			}
+			if got := b.State(); got != tc.wantState {
+				t.Fatalf("got %v; want %v", got, tc.wantState)
+			}
+		})
+	}
+}
+
+type failingProvider struct {
+	calls int
+	opts  Options
+}
+
+func (p *failingProvider) Name() string     { return "failing" }
+func (p *failingProvider) Kind() string     { return "test" }
+func (p *failingProvider) Options() Options { return p.opts }
+func (p *failingProvider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	p.calls++
+	return nil, errors.New("service unavailable")
+}
+
+func TestBlogService_FetchSourceBreaker(t *testing.T) {
+	b := BlogService{}
+	p := &failingProvider{opts: Options{Breaker: BreakerPolicy{Threshold: 2, Cooldown: time.Hour}}}
+	b.Add(p)
+
+	for i := 0; i < 5; i++ {
+		b.FetchSource(context.Background(), p)
+	}
+	if got, want := p.calls, 2; got != want {
+		t.Fatalf("got %v calls; want %v", got, want)
+	}
+	if _, err := b.FetchSource(context.Background(), p); err != ErrCircuitOpen {
+		t.Fatalf("got %v; want %v", err, ErrCircuitOpen)
+	}
+
+	src := b.Sources()[0]
+	if got, want := src.Breaker, BreakerOpen; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got, want := src.Err.Error(), "service unavailable"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+package blogservice
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Decoder decodes a response of a blog service API into a value
+type Decoder func(header http.Header, body []byte) (interface{}, error)
+
+// Client is an HTTP client for blog service APIs.
+// It remembers the ETag and Last-Modified of each endpoint and sends conditional requests with them,
+// so that the value decoded from the previous response is reused when the endpoint is not modified.
+// Idempotent requests failed transiently are retried by Retry.
+// The zero value is ready to use and does not retry.
+type Client struct {
+	Retry RetryPolicy
+	// OnResponse is called with every response from the blog service if it is not nil
+	OnResponse func(res *http.Response)
+
+	mu        sync.Mutex
+	responses map[string]*response
+}
+
+// NewClient returns a Client which retries by the policy of opts
+func NewClient(opts Options) *Client {
+	return &Client{Retry: opts.Retry}
+}
+
+// response is the last response of an endpoint
+type response struct {
+	etag         string
+	lastModified string
+	value        interface{}
+}
+
+// StatusError is returned by Client when a blog service API responds an unexpected status
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("request failed: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Get sends req with ctx and returns the response decoded by decode.
+// If the endpoint responds 304 Not Modified, the value decoded from the previous response is returned.
+func (c *Client) Get(ctx context.Context, req *http.Request, decode Decoder) (interface{}, error) {
+	endpoint := req.URL.String()
+	prev := c.response(endpoint)
+	if prev != nil {
+		if prev.etag != "" {
+			req.Header.Set("If-None-Match", prev.etag)
+		}
+		if prev.lastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.lastModified)
+		}
+	}
+
+	res, err := c.do(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && prev != nil:
+		return prev.value, nil
+	case res.StatusCode != http.StatusOK:
+		return nil, &StatusError{StatusCode: res.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	v, err := decode(res.Header, body)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode response")
+	}
+
+	etag, lastModified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	if etag != "" || lastModified != "" {
+		c.store(endpoint, &response{etag: etag, lastModified: lastModified, value: v})
+	} else if prev != nil {
+		c.store(endpoint, nil)
+	}
+	return v, nil
+}
+
+// do sends req with ctx, and sends it again while it fails transiently up to the max retries.
+// A retry is given up if its wait would exceed the deadline of ctx,
+// or if the Retry-After of the response exceeds the max backoff.
+func (c *Client) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	for n := 1; ; n++ {
+		res, err := HTTPClient.Do(req.WithContext(ctx))
+		if err == nil && c.OnResponse != nil {
+			c.OnResponse(res)
+		}
+		if n > c.Retry.MaxRetries || !idempotent(req) || !retryable(ctx, res, err) {
+			return res, err
+		}
+
+		wait := retryAfter(res, time.Now())
+		if c.Retry.MaxBackoff > 0 && wait > c.Retry.MaxBackoff {
+			return res, err
+		}
+		if wait == 0 {
+			wait = c.Retry.backoff(n)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			return res, err
+		}
+		if res != nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) response(endpoint string) *response {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.responses[endpoint]
+}
+
+// store remembers res of endpoint. Nil res forgets endpoint.
+func (c *Client) store(endpoint string, res *response) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if res == nil {
+		delete(c.responses, endpoint)
+		return
+	}
+	if c.responses == nil {
+		c.responses = make(map[string]*response)
+	}
+	c.responses[endpoint] = res
+}
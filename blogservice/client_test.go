@@ -0,0 +1,127 @@
+package blogservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClient_Get(t *testing.T) {
+	cases := []struct {
+		name            string
+		etag            string
+		lastModified    string
+		wantConditional bool
+	}{
+		{name: "etag", etag: `"v1"`, wantConditional: true},
+		{name: "last modified", lastModified: "Wed, 28 Nov 2018 00:00:00 GMT", wantConditional: true},
+		{name: "both", etag: `"v1"`, lastModified: "Wed, 28 Nov 2018 00:00:00 GMT", wantConditional: true},
+		{name: "no validator", wantConditional: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var requests, notModified int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if (tc.etag != "" && r.Header.Get("If-None-Match") == tc.etag) ||
+					(tc.etag == "" && tc.lastModified != "" && r.Header.Get("If-Modified-Since") == tc.lastModified) {
+					notModified++
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				if tc.etag != "" {
+					w.Header().Set("ETag", tc.etag)
+				}
+				if tc.lastModified != "" {
+					w.Header().Set("Last-Modified", tc.lastModified)
+				}
+				fmt.Fprint(w, "body")
+			}))
+			defer ts.Close()
+
+			var decoded int
+			decode := func(header http.Header, body []byte) (interface{}, error) {
+				decoded++
+				return string(body), nil
+			}
+
+			var c Client
+			for i := 0; i < 3; i++ {
+				req, _ := http.NewRequest("GET", ts.URL, nil)
+				v, err := c.Get(context.Background(), req, decode)
+				if err != nil {
+					t.Fatal("Get failed: ", err)
+				}
+				if got, want := v, "body"; got != want {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+			}
+
+			if got, want := requests, 3; got != want {
+				t.Fatalf("got %v requests; want %v", got, want)
+			}
+			wantNotModified, wantDecoded := 0, 3
+			if tc.wantConditional {
+				wantNotModified, wantDecoded = 2, 1
+			}
+			if got, want := notModified, wantNotModified; got != want {
+				t.Fatalf("got %v not modified responses; want %v", got, want)
+			}
+			if got, want := decoded, wantDecoded; got != want {
+				t.Fatalf("got %v decodes; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestClient_Get_Modified(t *testing.T) {
+	version := 1
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"v%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, "body-%d", version)
+	}))
+	defer ts.Close()
+
+	decode := func(header http.Header, body []byte) (interface{}, error) {
+		return string(body), nil
+	}
+
+	var c Client
+	for _, want := range []string{"body-1", "body-1", "body-2", "body-2"} {
+		if want == "body-2" {
+			version = 2
+		}
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		v, err := c.Get(context.Background(), req, decode)
+		if err != nil {
+			t.Fatal("Get failed: ", err)
+		}
+		if v != want {
+			t.Fatalf("got %v; want %v", v, want)
+		}
+	}
+}
+
+func TestClient_Get_StatusError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	}))
+	defer ts.Close()
+
+	var c Client
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	_, err := c.Get(context.Background(), req, func(header http.Header, body []byte) (interface{}, error) {
+		return nil, nil
+	})
+	if e, ok := err.(*StatusError); !ok || e.StatusCode != http.StatusNotModified {
+		t.Fatalf("got %v; want StatusError of 304", err)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"encoding/xml"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,98 +14,254 @@ import (
 	"golang.org/x/tools/blog/atom"
 )
 
-const baseURL = "https://blog.hatena.ne.jp"
+var baseURL = "https://blog.hatena.ne.jp"
+
+const kind = "hatenablog"
+
+const defaultMaxPages = 50
+
+func init() {
+	blogservice.Register(kind, New)
+}
 
 type Control struct {
 	Draft string `xml:"http://www.w3.org/2007/app draft"`
 }
 
+type Category struct {
+	Term string `xml:"term,attr"`
+}
+
 type Entry struct {
 	atom.Entry
-	Control Control `xml:"http://www.w3.org/2007/app control"`
+	Control          Control    `xml:"http://www.w3.org/2007/app control"`
+	Categories       []Category `xml:"category"`
+	FormattedContent string     `xml:"http://www.hatena.ne.jp/info/xmlns# formatted-content"`
 }
 
 // Result for hatenablog correction uri
 type Result struct {
-	Entries []Entry `xml:"entry"`
+	Links   []atom.Link `xml:"link"`
+	Entries []Entry     `xml:"entry"`
 }
 
-// FetchEntries fetch entry list of hatena blog
-func FetchEntries(ctx context.Context, userID, blogID, apiKey string) ([]structs.Entry, error) {
-	endpoint := fmt.Sprintf("%s/%s/%s/atom/entry", baseURL, userID, blogID)
+// next returns the uri of the next page, or empty string on the last page
+func (r *Result) next() string {
+	for _, l := range r.Links {
+		if l.Rel == "next" {
+			return l.Href
+		}
+	}
+	return ""
+}
 
-	req, err := http.NewRequest("GET", endpoint, nil)
+// Provider provides entries of a hatena blog
+type Provider struct {
+	userID string
+	blogID string
+	apiKey string
+	opts   blogservice.Options
+
+	maxPages   int
+	maxEntries int
+
+	client *blogservice.Client
+}
+
+// New returns a hatenablog provider configured by HATENA_ID, HATENA_BLOG_ID, HATENA_BLOG_API_KEY,
+// HATENA_MAX_PAGES, HATENA_MAX_ENTRIES and the HATENA_ prefixed options
+func New(conf blogservice.Config) (blogservice.Provider, error) {
+	userID := conf("HATENA_ID")
+	if userID == "" {
+		return nil, blogservice.ErrNotConfigured
+	}
+	blogID := conf("HATENA_BLOG_ID")
+	if blogID == "" {
+		return nil, errors.New("HATENA_BLOG_ID is required")
+	}
+	opts, err := blogservice.ParseOptions(conf, "HATENA_")
+	if err != nil {
+		return nil, err
+	}
+	maxPages, err := conf.Int("HATENA_MAX_PAGES", defaultMaxPages)
+	if err != nil {
+		return nil, err
+	}
+	maxEntries, err := conf.Int("HATENA_MAX_ENTRIES", 0)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch hatenablog entries: %s", err.Error())
+		return nil, err
 	}
+	return &Provider{
+		userID:     userID,
+		blogID:     blogID,
+		apiKey:     conf("HATENA_BLOG_API_KEY"),
+		opts:       opts,
+		maxPages:   maxPages,
+		maxEntries: maxEntries,
+		client:     blogservice.NewClient(opts),
+	}, nil
+}
 
-	req.SetBasicAuth(userID, apiKey)
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return kind + ":" + p.blogID
+}
 
-	req = req.WithContext(ctx)
+// Kind returns "hatenablog"
+func (p *Provider) Kind() string {
+	return kind
+}
 
-	var body []byte
-	errCh := make(chan error)
-	doneCh := make(chan struct{})
-	go func() {
-		res, err := blogservice.HTTPClient.Do(req)
-		if err != nil {
-			errCh <- err
-			return
-		}
+// Options returns the provider options
+func (p *Provider) Options() blogservice.Options {
+	return p.opts
+}
+
+// Fetch fetches entries of the hatena blog.
+// Pages not modified since the last fetch are reused.
+func (p *Provider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	return fetchEntries(ctx, p.client, p.userID, p.blogID, p.apiKey, p.maxPages, p.maxEntries)
+}
+
+// FetchEntries fetch entry list of hatena blog.
+// It follows the next links of the collection up to maxPages pages and maxEntries entries.
+// Zero maxPages or maxEntries means no limit.
+func FetchEntries(ctx context.Context, userID, blogID, apiKey string, maxPages, maxEntries int) ([]structs.Entry, error) {
+	return fetchEntries(ctx, &blogservice.Client{}, userID, blogID, apiKey, maxPages, maxEntries)
+}
 
-		if res.StatusCode != http.StatusOK {
-			errCh <- errors.New("request failed")
-			return
+func fetchEntries(ctx context.Context, client *blogservice.Client, userID, blogID, apiKey string, maxPages, maxEntries int) ([]structs.Entry, error) {
+	endpoint := fmt.Sprintf("%s/%s/%s/atom/entry", baseURL, userID, blogID)
+
+	var entries []structs.Entry
+
+	for page := 1; endpoint != ""; page++ {
+		if maxPages > 0 && page > maxPages {
+			break
 		}
 
-		body, err = ioutil.ReadAll(res.Body)
-		defer res.Body.Close()
+		r, err := fetchPage(ctx, client, endpoint, userID, apiKey)
 		if err != nil {
-			errCh <- err
-			return
+			return nil, err
 		}
-		doneCh <- struct{}{}
-	}()
 
-	var r Result
+		for _, e := range r.Entries {
+			if e.Control.Draft == "yes" {
+				continue
+			}
+
+			entry, err := e.toEntry()
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+			if maxEntries > 0 && len(entries) >= maxEntries {
+				return entries, nil
+			}
+		}
 
-	select {
-	case err := <-errCh:
-		return nil, fmt.Errorf("failed to fetch hatenablog entries: %s", err.Error())
-	case <-doneCh:
-		if err := xml.Unmarshal(body, &r); err != nil {
-			return nil, fmt.Errorf("failed to parse xml: %s", err.Error())
+		if endpoint, err = nextEndpoint(endpoint, r.next()); err != nil {
+			return nil, err
 		}
 	}
 
-	var entries []structs.Entry
+	return entries, nil
+}
 
-	for _, e := range r.Entries {
-		if e.Control.Draft == "yes" {
-			continue
-		}
+// nextEndpoint resolves the next link against endpoint.
+// Links out of baseURL are rejected since the credentials are sent to them.
+func nextEndpoint(endpoint, next string) (string, error) {
+	if next == "" {
+		return "", nil
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch hatenablog entries")
+	}
+	cur, err := url.Parse(endpoint)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch hatenablog entries")
+	}
+	u, err := cur.Parse(next)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch hatenablog entries")
+	}
+	if u.Scheme != base.Scheme || u.Host != base.Host {
+		return "", fmt.Errorf("failed to fetch hatenablog entries: next link out of %s: %s", baseURL, next)
+	}
+	return u.String(), nil
+}
 
-		title := e.Title
+const timeLayout = "2006-01-02T15:04:05-07:00"
 
-		var url string
-		for _, l := range e.Link {
-			if l.Rel == "alternate" {
-				url = l.Href
-			}
+func (e *Entry) toEntry() (structs.Entry, error) {
+	var url string
+	for _, l := range e.Link {
+		if l.Rel == "alternate" {
+			url = l.Href
 		}
+	}
 
-		layout := "2006-01-02T15:04:05-07:00"
-		createdAt, err := time.Parse(layout, string(e.Published))
-		if err != nil {
-			return nil, err
+	createdAt, err := time.Parse(timeLayout, string(e.Published))
+	if err != nil {
+		return structs.Entry{}, err
+	}
+	updatedAt := createdAt
+	if e.Updated != "" {
+		if updatedAt, err = time.Parse(timeLayout, string(e.Updated)); err != nil {
+			return structs.Entry{}, err
 		}
+	}
 
-		entries = append(entries, structs.Entry{
-			Title:     title,
-			URL:       url,
-			CreatedAt: createdAt,
-		})
+	var author string
+	if e.Author != nil {
+		author = e.Author.Name
 	}
 
-	return entries, nil
+	var summary string
+	if e.Summary != nil {
+		summary = blogservice.Excerpt(e.Summary.Body)
+	}
+
+	var tags []string
+	for _, c := range e.Categories {
+		tags = append(tags, c.Term)
+	}
+
+	return structs.Entry{
+		ID:        kind + ":" + e.ID,
+		Source:    kind,
+		Title:     e.Title,
+		URL:       url,
+		Author:    author,
+		Summary:   summary,
+		Tags:      tags,
+		Thumbnail: blogservice.FirstImage(e.FormattedContent),
+		CreatedAt: createdAt,
+		UpdatedAt: updatedAt,
+	}, nil
+}
+
+func fetchPage(ctx context.Context, client *blogservice.Client, endpoint, userID, apiKey string) (*Result, error) {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch hatenablog entries")
+	}
+
+	req.SetBasicAuth(userID, apiKey)
+
+	v, err := client.Get(ctx, req, decodeResult)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch hatenablog entries")
+	}
+
+	return v.(*Result), nil
+}
+
+func decodeResult(header http.Header, body []byte) (interface{}, error) {
+	var r Result
+	if err := xml.Unmarshal(body, &r); err != nil {
+		return nil, errors.Wrap(err, "failed to parse xml")
+	}
+	return &r, nil
 }
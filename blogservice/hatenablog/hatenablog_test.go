@@ -0,0 +1,282 @@
+package hatenablog
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+const testUserID = "testuser"
+const testBlogID = "testuser.hatenablog.com"
+const testAPIKey = "secret"
+
+// newTestServer serves a collection of the given number of pages with 3 entries each.
+// The first entry of the first page is a draft. requests counts the requests to the server.
+func newTestServer(pages int) (requests *int32, teardown func()) {
+	requests = new(int32)
+	mux := http.NewServeMux()
+	var srv *httptest.Server
+	mux.HandleFunc(fmt.Sprintf("/%s/%s/atom/entry", testUserID, testBlogID), func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if u, p, ok := r.BasicAuth(); !ok || u != testUserID || p != testAPIKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		page := 1
+		if v := r.URL.Query().Get("page"); v != "" {
+			fmt.Sscanf(v, "%d", &page)
+		}
+
+		lastModified := fmt.Sprintf("Wed, %02d Nov 2018 03:00:00 GMT", 28-page)
+		if r.Header.Get("If-Modified-Since") == lastModified {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Last-Modified", lastModified)
+
+		var b strings.Builder
+		b.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+		b.WriteString(`<feed xmlns="http://www.w3.org/2005/Atom" xmlns:app="http://www.w3.org/2007/app">`)
+		if page < pages {
+			fmt.Fprintf(&b, `<link rel="next" href="%s%s?page=%d"/>`, srv.URL, r.URL.Path, page+1)
+		}
+		for i := 1; i <= 3; i++ {
+			draft := "no"
+			if page == 1 && i == 1 {
+				draft = "yes"
+			}
+			fmt.Fprintf(&b, `<entry>
+<id>tag:blog.hatena.ne.jp,2013:blog-%s-%d-%d</id>
+<title>page%d-%d</title>
+<link rel="alternate" type="text/html" href="https://%s/entry/%d/%d"/>
+<author><name>%s</name></author>
+<published>2018-11-%02dT10:00:00+09:00</published>
+<updated>2018-11-%02dT12:00:00+09:00</updated>
+<summary type="text">summary of page%d-%d</summary>
+<hatena:formatted-content type="text/html" xmlns:hatena="http://www.hatena.ne.jp/info/xmlns#">&lt;p&gt;&lt;img src="https://cdn.example.com/%d/%d.png"&gt;&lt;/p&gt;</hatena:formatted-content>
+<category term="go"/>
+<category term="page%d"/>
+<app:control><app:draft>%s</app:draft></app:control>
+</entry>`, testUserID, page, i, page, i, testBlogID, page, i, testUserID, 28-page, 28-page, page, i, page, i, page, draft)
+		}
+		b.WriteString(`</feed>`)
+
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		fmt.Fprint(w, b.String())
+	})
+	srv = httptest.NewServer(mux)
+
+	orig := baseURL
+	baseURL = srv.URL
+	return requests, func() {
+		baseURL = orig
+		srv.Close()
+	}
+}
+
+func TestFetchEntries(t *testing.T) {
+	cases := []struct {
+		name         string
+		pages        int
+		maxPages     int
+		maxEntries   int
+		want         []string
+		wantRequests int32
+	}{
+		{
+			name:         "single page",
+			pages:        1,
+			want:         []string{"page1-2", "page1-3"},
+			wantRequests: 1,
+		},
+		{
+			name:         "multiple pages",
+			pages:        3,
+			want:         []string{"page1-2", "page1-3", "page2-1", "page2-2", "page2-3", "page3-1", "page3-2", "page3-3"},
+			wantRequests: 3,
+		},
+		{
+			name:         "page cap",
+			pages:        3,
+			maxPages:     2,
+			want:         []string{"page1-2", "page1-3", "page2-1", "page2-2", "page2-3"},
+			wantRequests: 2,
+		},
+		{
+			name:         "max entries",
+			pages:        3,
+			maxEntries:   4,
+			want:         []string{"page1-2", "page1-3", "page2-1", "page2-2"},
+			wantRequests: 2,
+		},
+		{
+			name:         "max entries at the end of a page",
+			pages:        3,
+			maxEntries:   5,
+			want:         []string{"page1-2", "page1-3", "page2-1", "page2-2", "page2-3"},
+			wantRequests: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			requests, teardown := newTestServer(tc.pages)
+			defer teardown()
+
+			entries, err := FetchEntries(context.Background(), testUserID, testBlogID, testAPIKey, tc.maxPages, tc.maxEntries)
+			if err != nil {
+				t.Fatal("FetchEntries failed: ", err)
+			}
+
+			var got []string
+			for _, e := range entries {
+				got = append(got, e.Title)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Fatalf("got %v; want %v", got, tc.want)
+			}
+			if got := atomic.LoadInt32(requests); got != tc.wantRequests {
+				t.Fatalf("got %v requests; want %v", got, tc.wantRequests)
+			}
+		})
+	}
+}
+
+func TestFetchEntries_Unauthorized(t *testing.T) {
+	_, teardown := newTestServer(1)
+	defer teardown()
+
+	if _, err := FetchEntries(context.Background(), testUserID, testBlogID, "wrong", 0, 0); err == nil {
+		t.Fatal("got nil; want error")
+	}
+}
+
+func TestFetchEntries_ForeignNextLink(t *testing.T) {
+	var requests int
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer foreign.Close()
+
+	// next returns the next link of the server at srvURL
+	cases := []struct {
+		name string
+		next func(srvURL string) string
+	}{
+		{name: "other host", next: func(string) string { return foreign.URL + "/atom/entry?page=2" }},
+		{name: "other scheme", next: func(srvURL string) string {
+			return strings.Replace(srvURL, "http://", "https://", 1) + "/atom/entry?page=2"
+		}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var srv *httptest.Server
+			srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("page") != "" {
+					requests++
+				}
+				fmt.Fprintf(w, `<feed xmlns="http://www.w3.org/2005/Atom"><link rel="next" href="%s"/></feed>`, tc.next(srv.URL))
+			}))
+			defer srv.Close()
+			orig := baseURL
+			baseURL = srv.URL
+			defer func() { baseURL = orig }()
+
+			if _, err := FetchEntries(context.Background(), testUserID, testBlogID, testAPIKey, 0, 0); err == nil {
+				t.Fatal("got nil; want error")
+			}
+			if requests != 0 {
+				t.Fatalf("got %v requests of the next page; want 0", requests)
+			}
+		})
+	}
+}
+
+func TestFetchEntries_Fields(t *testing.T) {
+	_, teardown := newTestServer(1)
+	defer teardown()
+
+	entries, err := FetchEntries(context.Background(), testUserID, testBlogID, testAPIKey, 0, 0)
+	if err != nil {
+		t.Fatal("FetchEntries failed: ", err)
+	}
+
+	want := structs.Entry{
+		ID:        "hatenablog:tag:blog.hatena.ne.jp,2013:blog-testuser-1-2",
+		Source:    "hatenablog",
+		Title:     "page1-2",
+		URL:       "https://testuser.hatenablog.com/entry/1/2",
+		Author:    testUserID,
+		Summary:   "summary of page1-2",
+		Tags:      []string{"go", "page1"},
+		Thumbnail: "https://cdn.example.com/1/2.png",
+		CreatedAt: time.Date(2018, 11, 27, 1, 0, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2018, 11, 27, 3, 0, 0, 0, time.UTC),
+	}
+	got := entries[0]
+	if !got.CreatedAt.Equal(want.CreatedAt) || !got.UpdatedAt.Equal(want.UpdatedAt) {
+		t.Fatalf("got %v, %v; want %v, %v", got.CreatedAt, got.UpdatedAt, want.CreatedAt, want.UpdatedAt)
+	}
+	got.CreatedAt, got.UpdatedAt = want.CreatedAt, want.UpdatedAt
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v; want %+v", got, want)
+	}
+}
+
+// statusCounter counts the responses by status code
+type statusCounter struct {
+	counts map[int]int
+}
+
+func (c *statusCounter) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := http.DefaultTransport.RoundTrip(req)
+	if err == nil {
+		c.counts[res.StatusCode]++
+	}
+	return res, err
+}
+
+func TestProvider_NotModified(t *testing.T) {
+	_, teardown := newTestServer(3)
+	defer teardown()
+
+	counter := &statusCounter{counts: make(map[int]int)}
+	orig := blogservice.HTTPClient
+	blogservice.HTTPClient = &http.Client{Transport: counter}
+	defer func() { blogservice.HTTPClient = orig }()
+
+	p, err := New(blogservice.Config(func(key string) string {
+		return map[string]string{"HATENA_ID": testUserID, "HATENA_BLOG_ID": testBlogID, "HATENA_BLOG_API_KEY": testAPIKey}[key]
+	}))
+	if err != nil {
+		t.Fatal("New failed: ", err)
+	}
+
+	first, err := p.Fetch(context.Background())
+	if err != nil {
+		t.Fatal("Fetch failed: ", err)
+	}
+	second, err := p.Fetch(context.Background())
+	if err != nil {
+		t.Fatal("Fetch failed: ", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("got %v; want %v", second, first)
+	}
+	want := map[int]int{http.StatusOK: 3, http.StatusNotModified: 3}
+	if !reflect.DeepEqual(counter.counts, want) {
+		t.Fatalf("got %v; want %v", counter.counts, want)
+	}
+}
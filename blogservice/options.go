@@ -0,0 +1,81 @@
+package blogservice
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Options holds the settings common to every provider
+type Options struct {
+	// Timeout bounds a fetch from the provider. Zero means the BlogService default.
+	Timeout time.Duration
+	// RefreshInterval is the interval of background refreshes of the provider. Zero means the server default.
+	RefreshInterval time.Duration
+	// Retry is the retry policy of requests to the blog service
+	Retry RetryPolicy
+	// Breaker is the circuit breaker policy of the provider
+	Breaker BreakerPolicy
+}
+
+// Configurable is implemented by providers which have Options
+type Configurable interface {
+	Options() Options
+}
+
+// ParseOptions reads the common settings of a provider from conf.
+// Keys are prefixed with prefix, e.g. "QIITA_" for "QIITA_TIMEOUT".
+// The retry policy is DefaultRetryPolicy unless it is overridden by MAX_RETRIES, RETRY_BACKOFF and RETRY_MAX_BACKOFF,
+// and the breaker policy is DefaultBreakerPolicy unless it is overridden by BREAKER_THRESHOLD and BREAKER_COOLDOWN.
+func ParseOptions(conf Config, prefix string) (Options, error) {
+	var o Options
+	var err error
+	if o.Timeout, err = conf.Duration(prefix+"TIMEOUT", 0); err != nil {
+		return o, err
+	}
+	if o.RefreshInterval, err = conf.Duration(prefix+"REFRESH_INTERVAL", 0); err != nil {
+		return o, err
+	}
+	if o.Retry.MaxRetries, err = conf.Int(prefix+"MAX_RETRIES", DefaultRetryPolicy.MaxRetries); err != nil {
+		return o, err
+	}
+	if o.Retry.Backoff, err = conf.Duration(prefix+"RETRY_BACKOFF", DefaultRetryPolicy.Backoff); err != nil {
+		return o, err
+	}
+	if o.Retry.MaxBackoff, err = conf.Duration(prefix+"RETRY_MAX_BACKOFF", DefaultRetryPolicy.MaxBackoff); err != nil {
+		return o, err
+	}
+	if o.Breaker.Threshold, err = conf.Int(prefix+"BREAKER_THRESHOLD", DefaultBreakerPolicy.Threshold); err != nil {
+		return o, err
+	}
+	if o.Breaker.Cooldown, err = conf.Duration(prefix+"BREAKER_COOLDOWN", DefaultBreakerPolicy.Cooldown); err != nil {
+		return o, err
+	}
+	return o, nil
+}
+
+// Int returns the setting of key as an int, or def if the setting is absent
+func (c Config) Int(key string, def int) (int, error) {
+	v := c(key)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, err.Error())
+	}
+	return n, nil
+}
+
+// Duration returns the setting of key as a time.Duration, or def if the setting is absent
+func (c Config) Duration(key string, def time.Duration) (time.Duration, error) {
+	v := c(key)
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", key, err.Error())
+	}
+	return d, nil
+}
@@ -0,0 +1,66 @@
+package qiita
+
+import (
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// Item is an item of the qiita api v2.
+// See https://qiita.com/api/v2/docs#%E6%8A%95%E7%A8%BF
+type Item struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	URL            string    `json:"url"`
+	Body           string    `json:"body"`
+	RenderedBody   string    `json:"rendered_body"`
+	Coediting      bool      `json:"coediting"`
+	CommentsCount  int       `json:"comments_count"`
+	LikesCount     int       `json:"likes_count"`
+	ReactionsCount int       `json:"reactions_count"`
+	Private        bool      `json:"private"`
+	Tags           []Tag     `json:"tags"`
+	User           User      `json:"user"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Tag is a tagging of an item
+type Tag struct {
+	Name     string   `json:"name"`
+	Versions []string `json:"versions"`
+}
+
+// User is the author of an item
+type User struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	ProfileImageURL string `json:"profile_image_url"`
+}
+
+// Entry maps the item to an entry
+func (it *Item) Entry() structs.Entry {
+	var tags []string
+	for _, t := range it.Tags {
+		tags = append(tags, t.Name)
+	}
+
+	author := it.User.Name
+	if author == "" {
+		author = it.User.ID
+	}
+
+	return structs.Entry{
+		ID:        kind + ":" + it.ID,
+		Source:    kind,
+		Title:     it.Title,
+		URL:       it.URL,
+		Author:    author,
+		Summary:   blogservice.Excerpt(it.RenderedBody),
+		Tags:      tags,
+		Thumbnail: blogservice.FirstImage(it.RenderedBody),
+		CreatedAt: it.CreatedAt,
+		UpdatedAt: it.UpdatedAt,
+	}
+}
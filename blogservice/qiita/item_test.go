@@ -0,0 +1,70 @@
+package qiita
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+func TestFetchEntries_Fixture(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Total-Count", "2")
+		http.ServeFile(w, r, "testdata/items.json")
+	}))
+	defer srv.Close()
+	orig := baseURL
+	baseURL = srv.URL
+	defer func() { baseURL = orig }()
+
+	entries, err := FetchEntries(context.Background(), "testuser", 20, 0)
+	if err != nil {
+		t.Fatal("FetchEntries failed: ", err)
+	}
+
+	jst := time.FixedZone("", 9*60*60)
+	want := []structs.Entry{
+		{
+			ID:        "qiita:3c5ae2c6a5b1f7a0e8d4",
+			Source:    "qiita",
+			Title:     "Go の context でキャンセルを伝播させる",
+			URL:       "https://qiita.com/testuser/items/3c5ae2c6a5b1f7a0e8d4",
+			Author:    "Test User",
+			Summary:   "はじめに Go の context パッケージでキャンセルを伝播させる方法をまとめます。",
+			Tags:      []string{"Go", "golang"},
+			Thumbnail: "https://qiita-image-store.s3.amazonaws.com/0/12345/context.png",
+			CreatedAt: time.Date(2018, 11, 20, 21, 4, 16, 0, jst),
+			UpdatedAt: time.Date(2018, 11, 22, 9, 30, 0, 0, jst),
+		},
+		{
+			ID:        "qiita:a1b2c3d4e5f6a7b8c9d0",
+			Source:    "qiita",
+			Title:     "マルチステージビルドで Go のイメージを小さくする",
+			URL:       "https://qiita.com/testuser/items/a1b2c3d4e5f6a7b8c9d0",
+			Author:    "testuser",
+			Summary:   "Docker のマルチステージビルドで Go のバイナリを小さくする。",
+			Tags:      []string{"Docker"},
+			CreatedAt: time.Date(2018, 6, 1, 8, 0, 0, 0, jst),
+			UpdatedAt: time.Date(2018, 6, 1, 8, 0, 0, 0, jst),
+		},
+	}
+
+	if got, want := len(entries), len(want); got != want {
+		t.Fatalf("got %v entries; want %v", got, want)
+	}
+	for i := range want {
+		got := entries[i]
+		if !got.CreatedAt.Equal(want[i].CreatedAt) || !got.UpdatedAt.Equal(want[i].UpdatedAt) {
+			t.Fatalf("got %v, %v; want %v, %v", got.CreatedAt, got.UpdatedAt, want[i].CreatedAt, want[i].UpdatedAt)
+		}
+		got.CreatedAt, got.UpdatedAt = want[i].CreatedAt, want[i].UpdatedAt
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Fatalf("got %+v; want %+v", got, want[i])
+		}
+	}
+}
@@ -4,54 +4,214 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"strconv"
 
+	"github.com/pkg/errors"
 	"github.com/shiimaxx/blog-aggregator/blogservice"
 	"github.com/shiimaxx/blog-aggregator/structs"
+	"golang.org/x/sync/errgroup"
 )
 
-const baseURL = "https://qiita.com/api/v2"
+var baseURL = "https://qiita.com/api/v2"
 
-// FetchEntries fetch qiita entries of specified user id
-func FetchEntries(ctx context.Context, userID string) ([]structs.Entry, error) {
-	endpoint := fmt.Sprintf("%s/users/%s/items", baseURL, userID)
+const maxPerPage = 100
+
+const defaultMaxEntries = 500
+
+// maxConcurrentRequests bounds the page requests in flight at once.
+// It does not bound the requests per hour, which the rate budget does.
+const maxConcurrentRequests = 2
+
+const kind = "qiita"
+
+func init() {
+	blogservice.Register(kind, New)
+}
+
+// Provider provides entries of a qiita user
+type Provider struct {
+	userID string
+	opts   blogservice.Options
+
+	perPage    int
+	maxEntries int
+
+	client *blogservice.Client
+	budget *rateBudget
+}
+
+// New returns a qiita provider configured by QIITA_ID, QIITA_PER_PAGE, QIITA_MAX_ENTRIES,
+// QIITA_RATE_RESERVE and the QIITA_ prefixed options
+func New(conf blogservice.Config) (blogservice.Provider, error) {
+	userID := conf("QIITA_ID")
+	if userID == "" {
+		return nil, blogservice.ErrNotConfigured
+	}
+	opts, err := blogservice.ParseOptions(conf, "QIITA_")
+	if err != nil {
+		return nil, err
+	}
+	perPage, err := conf.Int("QIITA_PER_PAGE", maxPerPage)
+	if err != nil {
+		return nil, err
+	}
+	maxEntries, err := conf.Int("QIITA_MAX_ENTRIES", defaultMaxEntries)
+	if err != nil {
+		return nil, err
+	}
+	reserve, err := conf.Int("QIITA_RATE_RESERVE", defaultRateReserve)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{
+		userID:     userID,
+		opts:       opts,
+		perPage:    perPage,
+		maxEntries: maxEntries,
+		client:     blogservice.NewClient(opts),
+		budget:     newRateBudget(reserve),
+	}
+	p.client.OnResponse = p.budget.observe
+	return p, nil
+}
+
+// Name returns the provider name
+func (p *Provider) Name() string {
+	return kind + ":" + p.userID
+}
+
+// Kind returns "qiita"
+func (p *Provider) Kind() string {
+	return kind
+}
+
+// Options returns the provider options
+func (p *Provider) Options() blogservice.Options {
+	return p.opts
+}
+
+// RateLimit returns the rate limit of the qiita api known by the last fetch
+func (p *Provider) RateLimit() (blogservice.RateLimit, bool) {
+	return p.budget.rateLimit()
+}
+
+// Fetch fetches entries of the qiita user.
+// Pages not modified since the last fetch are reused.
+// It backs off with a RateLimitError before the rate limit gets exhausted.
+func (p *Provider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	return fetchEntries(ctx, p.client, p.budget, p.userID, p.perPage, p.maxEntries)
+}
+
+// FetchEntries fetch qiita entries of specified user id.
+// It reads the Total-Count header of the first page and fetches the rest of pages concurrently
+// up to maxEntries entries. Zero maxEntries means no limit.
+func FetchEntries(ctx context.Context, userID string, perPage, maxEntries int) ([]structs.Entry, error) {
+	return fetchEntries(ctx, &blogservice.Client{}, nil, userID, perPage, maxEntries)
+}
+
+func fetchEntries(ctx context.Context, client *blogservice.Client, budget *rateBudget, userID string, perPage, maxEntries int) ([]structs.Entry, error) {
+	if perPage <= 0 || perPage > maxPerPage {
+		perPage = maxPerPage
+	}
+	if maxEntries > 0 && maxEntries < perPage {
+		perPage = maxEntries
+	}
+
+	// the pages of the last fetch are reserved before the first request,
+	// so that a fetch does not spend a request on the first page only to back off after it
+	if err := budget.allow(budget.expected()); err != nil {
+		return nil, err
+	}
+	first, total, err := fetchPage(ctx, client, userID, 1, perPage)
+	if err != nil {
+		return nil, err
+	}
+	if total < len(first) {
+		total = len(first)
+	}
+	if maxEntries > 0 && total > maxEntries {
+		total = maxEntries
+	}
+	if total <= len(first) {
+		budget.expect(1)
+		return first[:total], nil
+	}
+
+	pages := (total + perPage - 1) / perPage
+	budget.expect(pages)
+	if err := budget.allow(pages - 1); err != nil {
+		return nil, err
+	}
+	results := make([][]structs.Entry, pages)
+	results[0] = first
+
+	eg, ctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, maxConcurrentRequests)
+	for page := 2; page <= pages; page++ {
+		page := page
+		eg.Go(func() error {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			e, _, err := fetchPage(ctx, client, userID, page, perPage)
+			if err != nil {
+				return err
+			}
+			results[page-1] = e
+			return nil
+		})
+	}
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	var entries []structs.Entry
+	for _, e := range results {
+		entries = append(entries, e...)
+	}
+	if len(entries) > total {
+		entries = entries[:total]
+	}
+
+	return entries, nil
+}
+
+// itemsPage is a page of qiita entries and the total count of entries of the user
+type itemsPage struct {
+	entries []structs.Entry
+	total   int
+}
+
+// fetchPage fetches a page of qiita entries and the total count of entries of the user
+func fetchPage(ctx context.Context, client *blogservice.Client, userID string, page, perPage int) ([]structs.Entry, int, error) {
+	endpoint := fmt.Sprintf("%s/users/%s/items?page=%d&per_page=%d", baseURL, userID, page, perPage)
 
 	req, err := http.NewRequest("GET", endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch qiita entries: %s", err.Error())
-	}
-
-	req = req.WithContext(ctx)
-
-	var body []byte
-	errCh := make(chan error)
-	doneCh := make(chan struct{})
-	go func() {
-		res, err := blogservice.HTTPClient.Do(req)
-		if err != nil {
-			errCh <- err
-			return
-		}
-		body, err = ioutil.ReadAll(res.Body)
-		defer res.Body.Close()
-		if err != nil {
-			errCh <- err
-			return
-		}
-		doneCh <- struct{}{}
-	}()
-
-	var e []structs.Entry
-
-	select {
-	case err := <-errCh:
-		return nil, fmt.Errorf("failed to fetch qiita entries: %s", err.Error())
-	case <-doneCh:
-		if err := json.Unmarshal(body, &e); err != nil {
-			return nil, fmt.Errorf("failed to fetch qiita entries: %s", err.Error())
-		}
-	}
-
-	return e, nil
+		return nil, 0, errors.Wrap(err, "failed to fetch qiita entries")
+	}
+
+	v, err := client.Get(ctx, req, decodePage)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to fetch qiita entries")
+	}
+	p := v.(*itemsPage)
+
+	return p.entries, p.total, nil
+}
+
+func decodePage(header http.Header, body []byte) (interface{}, error) {
+	var items []Item
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, err
+	}
+
+	entries := make([]structs.Entry, len(items))
+	for i, it := range items {
+		entries[i] = it.Entry()
+	}
+	total, _ := strconv.Atoi(header.Get("Total-Count"))
+
+	return &itemsPage{entries: entries, total: total}, nil
 }
@@ -0,0 +1,269 @@
+package qiita
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+)
+
+type testServer struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	requests    int
+	notModified int
+	inFlight    int
+	maxInFlight int
+}
+
+// rateReset is when the rate limit of the test server resets
+var rateReset = time.Now().Add(time.Hour)
+
+// newTestServer serves total items of testuser ordered by newest.
+// Its rate limit is 60 requests.
+func newTestServer(total int) (*testServer, func()) {
+	ts := &testServer{}
+	ts.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		ts.requests++
+		ts.inFlight++
+		if ts.inFlight > ts.maxInFlight {
+			ts.maxInFlight = ts.inFlight
+		}
+		ts.mu.Unlock()
+		defer func() {
+			ts.mu.Lock()
+			ts.inFlight--
+			ts.mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		if r.URL.Path != "/users/testuser/items" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+
+		ts.mu.Lock()
+		remaining := 60 - ts.requests
+		ts.mu.Unlock()
+		w.Header().Set("Rate-Limit", "60")
+		w.Header().Set("Rate-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("Rate-Reset", strconv.FormatInt(rateReset.Unix(), 10))
+
+		etag := fmt.Sprintf(`"%d-%d-%d"`, total, page, perPage)
+		if r.Header.Get("If-None-Match") == etag {
+			ts.mu.Lock()
+			ts.notModified++
+			ts.mu.Unlock()
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+
+		items := []map[string]interface{}{}
+		for i := (page - 1) * perPage; i < page*perPage && i < total; i++ {
+			createdAt := time.Date(2018, 11, 28, 0, 0, 0, 0, time.UTC).Add(-time.Duration(i) * time.Hour)
+			items = append(items, map[string]interface{}{
+				"id":            fmt.Sprintf("%020d", i),
+				"title":         fmt.Sprintf("item-%d", i),
+				"url":           fmt.Sprintf("https://qiita.com/testuser/items/%d", i),
+				"rendered_body": fmt.Sprintf("<h1>item-%d</h1><p>body of item-%d</p>", i, i),
+				"tags":          []map[string]interface{}{{"name": "Go", "versions": []string{}}},
+				"user":          map[string]string{"id": "testuser"},
+				"created_at":    createdAt.Format(time.RFC3339),
+				"updated_at":    createdAt.Add(time.Minute).Format(time.RFC3339),
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Header().Set("Total-Count", strconv.Itoa(total))
+		json.NewEncoder(w).Encode(items)
+	}))
+
+	orig := baseURL
+	baseURL = ts.URL
+	return ts, func() {
+		baseURL = orig
+		ts.Close()
+	}
+}
+
+func TestFetchEntries(t *testing.T) {
+	cases := []struct {
+		name         string
+		total        int
+		perPage      int
+		maxEntries   int
+		wantEntries  int
+		wantRequests int
+	}{
+		{name: "empty", total: 0, perPage: 20, wantEntries: 0, wantRequests: 1},
+		{name: "single page", total: 15, perPage: 20, wantEntries: 15, wantRequests: 1},
+		{name: "multiple pages", total: 95, perPage: 20, wantEntries: 95, wantRequests: 5},
+		{name: "max entries", total: 95, perPage: 20, maxEntries: 50, wantEntries: 50, wantRequests: 3},
+		{name: "max entries less than per page", total: 95, perPage: 20, maxEntries: 5, wantEntries: 5, wantRequests: 1},
+		{name: "default per page", total: 250, perPage: 0, wantEntries: 250, wantRequests: 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ts, teardown := newTestServer(tc.total)
+			defer teardown()
+
+			entries, err := FetchEntries(context.Background(), "testuser", tc.perPage, tc.maxEntries)
+			if err != nil {
+				t.Fatal("FetchEntries failed: ", err)
+			}
+			if got, want := len(entries), tc.wantEntries; got != want {
+				t.Fatalf("got %v entries; want %v", got, want)
+			}
+			for i, e := range entries {
+				if got, want := e.Title, fmt.Sprintf("item-%d", i); got != want {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+				if got, want := e.ID, fmt.Sprintf("qiita:%020d", i); got != want {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+				if got, want := e.Summary, fmt.Sprintf("item-%d body of item-%d", i, i); got != want {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+				if got, want := e.Tags, []string{"Go"}; !reflect.DeepEqual(got, want) {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+				if got, want := e.UpdatedAt.Sub(e.CreatedAt), time.Minute; got != want {
+					t.Fatalf("got %v; want %v", got, want)
+				}
+			}
+			if got, want := ts.requests, tc.wantRequests; got != want {
+				t.Fatalf("got %v requests; want %v", got, want)
+			}
+			if ts.maxInFlight > maxConcurrentRequests {
+				t.Fatalf("got %v concurrent requests; want at most %v", ts.maxInFlight, maxConcurrentRequests)
+			}
+		})
+	}
+}
+
+func TestFetchEntries_NotFound(t *testing.T) {
+	_, teardown := newTestServer(0)
+	defer teardown()
+
+	if _, err := FetchEntries(context.Background(), "nobody", 20, 0); err == nil {
+		t.Fatal("got nil; want error")
+	}
+}
+
+func TestProvider_NotModified(t *testing.T) {
+	ts, teardown := newTestServer(95)
+	defer teardown()
+
+	p, err := New(blogservice.Config(func(key string) string {
+		return map[string]string{"QIITA_ID": "testuser", "QIITA_PER_PAGE": "20"}[key]
+	}))
+	if err != nil {
+		t.Fatal("New failed: ", err)
+	}
+
+	first, err := p.Fetch(context.Background())
+	if err != nil {
+		t.Fatal("Fetch failed: ", err)
+	}
+	second, err := p.Fetch(context.Background())
+	if err != nil {
+		t.Fatal("Fetch failed: ", err)
+	}
+
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("got %v; want %v", second, first)
+	}
+	if got, want := ts.requests, 10; got != want {
+		t.Fatalf("got %v requests; want %v", got, want)
+	}
+	if got, want := ts.notModified, 5; got != want {
+		t.Fatalf("got %v not modified responses; want %v", got, want)
+	}
+}
+
+func TestProvider_RateLimit(t *testing.T) {
+	ts, teardown := newTestServer(95)
+	defer teardown()
+
+	p, err := New(blogservice.Config(func(key string) string {
+		return map[string]string{"QIITA_ID": "testuser", "QIITA_PER_PAGE": "20", "QIITA_RATE_RESERVE": "52"}[key]
+	}))
+	if err != nil {
+		t.Fatal("New failed: ", err)
+	}
+	if _, ok := p.(blogservice.RateLimiter).RateLimit(); ok {
+		t.Fatal("got known rate limit before fetch")
+	}
+
+	// 5 requests of the first fetch leave 55, then the second fetch stops before page 1
+	// since its 5 pages would break the reserve
+	if _, err := p.Fetch(context.Background()); err != nil {
+		t.Fatal("Fetch failed: ", err)
+	}
+	rl, ok := p.(blogservice.RateLimiter).RateLimit()
+	if !ok {
+		t.Fatal("got unknown rate limit after fetch")
+	}
+	if got, want := rl.Remaining, 55; got != want {
+		t.Fatalf("got %v remaining; want %v", got, want)
+	}
+	if got, want := rl.Limit, 60; got != want {
+		t.Fatalf("got %v limit; want %v", got, want)
+	}
+	if got, want := rl.Reset.Unix(), rateReset.Unix(); got != want {
+		t.Fatalf("got %v reset; want %v", got, want)
+	}
+
+	_, err = p.Fetch(context.Background())
+	if _, ok := err.(*blogservice.RateLimitError); !ok {
+		t.Fatalf("got %v; want RateLimitError", err)
+	}
+	if got, want := ts.requests, 5; got != want {
+		t.Fatalf("got %v requests; want %v", got, want)
+	}
+}
+
+func TestProvider_Canceled(t *testing.T) {
+	_, teardown := newTestServer(95)
+	defer teardown()
+
+	p, err := New(blogservice.Config(func(key string) string {
+		return map[string]string{"QIITA_ID": "testuser", "QIITA_PER_PAGE": "20", "QIITA_BREAKER_THRESHOLD": "2"}[key]
+	}))
+	if err != nil {
+		t.Fatal("New failed: ", err)
+	}
+	b := blogservice.BlogService{}
+	b.Add(p)
+
+	// the caller gives up while the test server sleeps
+	for i := 0; i < 5; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(time.Millisecond, cancel)
+		if _, err := b.FetchSource(ctx, p); err == nil {
+			t.Fatal("got nil; want error")
+		}
+	}
+
+	src := b.Sources()[0]
+	if got, want := src.Breaker, blogservice.BreakerClosed; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if src.Err != nil || !src.FetchedAt.IsZero() {
+		t.Fatalf("got %v fetched at %v; want no fetch recorded", src.Err, src.FetchedAt)
+	}
+}
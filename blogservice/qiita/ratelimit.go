@@ -0,0 +1,108 @@
+package qiita
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+)
+
+// defaultRateReserve is the number of requests left in the rate limit
+// when the provider starts backing off
+const defaultRateReserve = 10
+
+// rateBudget tracks the rate limit of the qiita api by the Rate-Limit, Rate-Remaining and Rate-Reset headers
+type rateBudget struct {
+	reserve int
+	now     func() time.Time
+
+	mu    sync.Mutex
+	limit blogservice.RateLimit
+	known bool
+	// pages is the number of pages requested by the last fetch
+	pages int
+}
+
+func newRateBudget(reserve int) *rateBudget {
+	return &rateBudget{reserve: reserve, now: time.Now}
+}
+
+// observe updates the budget by the headers of res
+func (b *rateBudget) observe(res *http.Response) {
+	limit, err := strconv.Atoi(res.Header.Get("Rate-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, err := strconv.Atoi(res.Header.Get("Rate-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(res.Header.Get("Rate-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+	l := blogservice.RateLimit{Limit: limit, Remaining: remaining, Reset: time.Unix(reset, 0)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// responses of concurrent requests can arrive out of order
+	if b.known && l.Reset.Equal(b.limit.Reset) && l.Remaining > b.limit.Remaining {
+		return
+	}
+	b.limit = l
+	b.known = true
+}
+
+// allow returns a RateLimitError if n more requests would leave less than the reserve of the budget.
+// The budget is replenished at the reset time. A nil budget allows any requests.
+func (b *rateBudget) allow(n int) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.known || !b.now().Before(b.limit.Reset) {
+		return nil
+	}
+	if b.limit.Remaining-n < b.reserve {
+		return &blogservice.RateLimitError{RateLimit: b.limit}
+	}
+	return nil
+}
+
+// rateLimit returns the last known rate limit
+func (b *rateBudget) rateLimit() (blogservice.RateLimit, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.limit, b.known
+}
+
+// expect remembers the number of pages requested by a fetch
+func (b *rateBudget) expect(pages int) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pages = pages
+}
+
+// expected returns the number of pages the next fetch is expected to request, which is at least one
+func (b *rateBudget) expected() int {
+	if b == nil {
+		return 1
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pages < 1 {
+		return 1
+	}
+	return b.pages
+}
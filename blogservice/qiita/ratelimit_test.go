@@ -0,0 +1,68 @@
+package qiita
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+)
+
+func rateResponse(limit, remaining int, reset time.Time) *http.Response {
+	res := &http.Response{Header: http.Header{}}
+	res.Header.Set("Rate-Limit", strconv.Itoa(limit))
+	res.Header.Set("Rate-Remaining", strconv.Itoa(remaining))
+	res.Header.Set("Rate-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return res
+}
+
+func TestRateBudget(t *testing.T) {
+	now := time.Date(2018, 11, 28, 10, 0, 0, 0, time.UTC)
+	reset := now.Add(30 * time.Minute)
+
+	cases := []struct {
+		name          string
+		responses     []*http.Response
+		elapsed       time.Duration
+		requests      int
+		wantKnown     bool
+		wantRemaining int
+		wantErr       bool
+	}{
+		{name: "unknown", requests: 5, wantKnown: false},
+		{name: "no rate headers", responses: []*http.Response{{Header: http.Header{}}}, requests: 5, wantKnown: false},
+		{name: "enough budget", responses: []*http.Response{rateResponse(60, 20, reset)}, requests: 5, wantKnown: true, wantRemaining: 20},
+		{name: "reserve reached", responses: []*http.Response{rateResponse(60, 14, reset)}, requests: 5, wantKnown: true, wantRemaining: 14, wantErr: true},
+		{name: "exhausted", responses: []*http.Response{rateResponse(60, 0, reset)}, requests: 1, wantKnown: true, wantRemaining: 0, wantErr: true},
+		{name: "reset elapsed", responses: []*http.Response{rateResponse(60, 0, reset)}, elapsed: 30 * time.Minute, requests: 1, wantKnown: true, wantRemaining: 0},
+		{name: "out of order", responses: []*http.Response{rateResponse(60, 12, reset), rateResponse(60, 13, reset)}, requests: 1, wantKnown: true, wantRemaining: 12},
+		{name: "next window", responses: []*http.Response{rateResponse(60, 12, reset.Add(-time.Hour)), rateResponse(60, 59, reset)}, requests: 1, wantKnown: true, wantRemaining: 59},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			b := newRateBudget(10)
+			b.now = func() time.Time { return now.Add(tc.elapsed) }
+			for _, res := range tc.responses {
+				b.observe(res)
+			}
+
+			rl, known := b.rateLimit()
+			if known != tc.wantKnown {
+				t.Fatalf("got %v; want %v", known, tc.wantKnown)
+			}
+			if known && rl.Remaining != tc.wantRemaining {
+				t.Fatalf("got %v; want %v", rl.Remaining, tc.wantRemaining)
+			}
+			err := b.allow(tc.requests)
+			if tc.wantErr {
+				if _, ok := err.(*blogservice.RateLimitError); !ok {
+					t.Fatalf("got %v; want RateLimitError", err)
+				}
+			} else if err != nil {
+				t.Fatalf("got %v; want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package blogservice
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RateLimit is the request budget of a blog service API
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RateLimiter is implemented by providers which know the rate limit of their blog service.
+// RateLimit returns false until the rate limit is known.
+type RateLimiter interface {
+	RateLimit() (RateLimit, bool)
+}
+
+// RateLimitError is returned by a provider which backs off to save the rest of its request budget
+type RateLimitError struct {
+	RateLimit RateLimit
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("backing off until %s: %d of %d requests remaining",
+		e.RateLimit.Reset.Format(time.RFC3339), e.RateLimit.Remaining, e.RateLimit.Limit)
+}
+
+// IsRateLimited reports whether err is caused by a provider backing off by its rate limit
+func IsRateLimited(err error) bool {
+	_, ok := errors.Cause(err).(*RateLimitError)
+	return ok
+}
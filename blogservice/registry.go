@@ -0,0 +1,70 @@
+package blogservice
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrNotConfigured is returned by a Factory when the config has no settings for its blog service
+var ErrNotConfigured = errors.New("not configured")
+
+// Config looks up a setting by key, e.g. "QIITA_ID". It returns empty string if the setting is absent
+type Config func(key string) string
+
+// Factory builds a Provider from config
+type Factory func(conf Config) (Provider, error)
+
+var (
+	factoriesMu sync.RWMutex
+	factories   = make(map[string]Factory)
+)
+
+// Register makes a blog service available by the provided kind.
+// It is intended to be called from the init function of blog service packages.
+func Register(kind string, factory Factory) {
+	factoriesMu.Lock()
+	defer factoriesMu.Unlock()
+
+	if factory == nil {
+		panic("blogservice: Register factory is nil")
+	}
+	if _, dup := factories[kind]; dup {
+		panic("blogservice: Register called twice for " + kind)
+	}
+	factories[kind] = factory
+}
+
+// Kinds returns a sorted list of the kinds of the registered blog services
+func Kinds() []string {
+	factoriesMu.RLock()
+	defer factoriesMu.RUnlock()
+
+	var kinds []string
+	for kind := range factories {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Open builds the providers of every registered blog service configured in conf
+func Open(conf Config) ([]Provider, error) {
+	var providers []Provider
+	for _, kind := range Kinds() {
+		factoriesMu.RLock()
+		factory := factories[kind]
+		factoriesMu.RUnlock()
+
+		p, err := factory(conf)
+		if err == ErrNotConfigured {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %s", kind, err.Error())
+		}
+		providers = append(providers, p)
+	}
+	return providers, nil
+}
@@ -0,0 +1,71 @@
+package blogservice
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+type testProvider struct {
+	id string
+}
+
+func (p *testProvider) Name() string { return "test:" + p.id }
+func (p *testProvider) Kind() string { return "test" }
+func (p *testProvider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	return nil, nil
+}
+
+func init() {
+	Register("test", func(conf Config) (Provider, error) {
+		id := conf("TEST_ID")
+		if id == "" {
+			return nil, ErrNotConfigured
+		}
+		if id == "broken" {
+			return nil, errors.New("broken config")
+		}
+		return &testProvider{id: id}, nil
+	})
+}
+
+func TestOpen(t *testing.T) {
+	cases := []struct {
+		name    string
+		env     map[string]string
+		want    []string
+		wantErr bool
+	}{
+		{name: "configured", env: map[string]string{"TEST_ID": "foo"}, want: []string{"test:foo"}},
+		{name: "not configured", env: map[string]string{}, want: nil},
+		{name: "broken", env: map[string]string{"TEST_ID": "broken"}, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			providers, err := Open(func(key string) string { return tc.env[key] })
+			if got := err != nil; got != tc.wantErr {
+				t.Fatalf("got error %v; want error %v", err, tc.wantErr)
+			}
+			var names []string
+			for _, p := range providers {
+				names = append(names, p.Name())
+			}
+			if !reflect.DeepEqual(names, tc.want) {
+				t.Fatalf("got %v; want %v", names, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegister_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("got no panic; want panic")
+		}
+	}()
+	Register("test", func(conf Config) (Provider, error) { return nil, ErrNotConfigured })
+}
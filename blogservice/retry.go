@@ -0,0 +1,108 @@
+package blogservice
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy is how failed requests to a blog service are retried.
+// The n-th retry waits for a random duration between the half and the whole of Backoff * 2^(n-1),
+// up to MaxBackoff, or for the Retry-After of the response unless it exceeds MaxBackoff.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. Zero disables retries.
+	MaxRetries int
+	// Backoff is the wait before the first retry
+	Backoff time.Duration
+	// MaxBackoff bounds the wait before a retry. Zero does not bound it.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is the retry policy of providers which do not configure one
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	Backoff:    500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+var (
+	randMu sync.Mutex
+	random = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// backoff returns the jittered wait before the n-th retry
+func (p RetryPolicy) backoff(n int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < n && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	randMu.Lock()
+	defer randMu.Unlock()
+	return d/2 + time.Duration(random.Int63n(int64(d/2)+1))
+}
+
+// idempotent reports whether req can be sent again
+func idempotent(req *http.Request) bool {
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a request which resulted in res and err may succeed if it is sent again
+func retryable(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		// errors caused by ctx are not transient
+		return ctx.Err() == nil
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the wait requested by the Retry-After header of res, or zero if it is absent
+func retryAfter(res *http.Response, now time.Time) time.Duration {
+	if res == nil {
+		return 0
+	}
+	v := res.Header.Get("Retry-After")
+	if v == "" {
+		return 0
+	}
+	if s, err := strconv.Atoi(v); err == nil && s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// sleep waits for d unless ctx is done first
+var sleep = func(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,220 @@
+package blogservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubSleep records the waits instead of sleeping
+func stubSleep() (waits func() []time.Duration, teardown func()) {
+	var mu sync.Mutex
+	var w []time.Duration
+	orig := sleep
+	sleep = func(ctx context.Context, d time.Duration) error {
+		mu.Lock()
+		defer mu.Unlock()
+		w = append(w, d)
+		return ctx.Err()
+	}
+	return func() []time.Duration {
+			mu.Lock()
+			defer mu.Unlock()
+			return w
+		}, func() {
+			sleep = orig
+		}
+}
+
+func TestClient_Retry(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	cases := []struct {
+		name         string
+		policy       RetryPolicy
+		statuses     []int
+		retryAfter   string
+		timeout      time.Duration
+		wantRequests int
+		wantStatus   int
+		wantWaits    []time.Duration
+	}{
+		{name: "success", policy: policy, statuses: []int{200}, wantRequests: 1, wantStatus: 200},
+		{name: "transient error", policy: policy, statuses: []int{503, 502, 200}, wantRequests: 3, wantStatus: 200},
+		{name: "retries exhausted", policy: policy, statuses: []int{500, 500, 500, 200}, wantRequests: 3, wantStatus: 500},
+		{name: "not retryable", policy: policy, statuses: []int{404, 200}, wantRequests: 1, wantStatus: 404},
+		{name: "no retries", statuses: []int{503, 200}, wantRequests: 1, wantStatus: 503},
+		{name: "retry after seconds", policy: policy, statuses: []int{429, 200}, retryAfter: "1", wantRequests: 2, wantStatus: 200, wantWaits: []time.Duration{time.Second}},
+		{name: "retry after beyond deadline", policy: policy, statuses: []int{429, 200}, retryAfter: "1", timeout: 500 * time.Millisecond, wantRequests: 1, wantStatus: 429},
+		{name: "retry after beyond max backoff", policy: policy, statuses: []int{429, 200}, retryAfter: "3", wantRequests: 1, wantStatus: 429},
+		{name: "retry after without max backoff", policy: RetryPolicy{MaxRetries: 1}, statuses: []int{429, 200}, retryAfter: "3", wantRequests: 2, wantStatus: 200, wantWaits: []time.Duration{3 * time.Second}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			waits, teardown := stubSleep()
+			defer teardown()
+
+			var requests int
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[requests]
+				requests++
+				if tc.retryAfter != "" && status != http.StatusOK {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(status)
+				fmt.Fprint(w, "body")
+			}))
+			defer ts.Close()
+
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			c := &Client{Retry: tc.policy}
+			req, _ := http.NewRequest("GET", ts.URL, nil)
+			_, err := c.Get(ctx, req, func(header http.Header, body []byte) (interface{}, error) {
+				return string(body), nil
+			})
+
+			if got, want := requests, tc.wantRequests; got != want {
+				t.Fatalf("got %v requests; want %v", got, want)
+			}
+			if tc.wantStatus == http.StatusOK {
+				if err != nil {
+					t.Fatal("Get failed: ", err)
+				}
+			} else if e, ok := err.(*StatusError); !ok || e.StatusCode != tc.wantStatus {
+				t.Fatalf("got %v; want StatusError of %v", err, tc.wantStatus)
+			}
+			if tc.wantWaits != nil && !reflect.DeepEqual(waits(), tc.wantWaits) {
+				t.Fatalf("got %v; want %v", waits(), tc.wantWaits)
+			}
+			if got, want := len(waits()), tc.wantRequests-1; got != want {
+				t.Fatalf("got %v waits; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestClient_RetryConnectionError(t *testing.T) {
+	_, teardown := stubSleep()
+	defer teardown()
+
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		fmt.Fprint(w, "body")
+	}))
+	defer ts.Close()
+
+	c := &Client{Retry: RetryPolicy{MaxRetries: 1, Backoff: time.Millisecond}}
+	req, _ := http.NewRequest("GET", ts.URL, nil)
+	v, err := c.Get(context.Background(), req, func(header http.Header, body []byte) (interface{}, error) {
+		return string(body), nil
+	})
+	if err != nil {
+		t.Fatal("Get failed: ", err)
+	}
+	if v != "body" || requests != 2 {
+		t.Fatalf("got %v after %v requests; want body after 2", v, requests)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	cases := []struct {
+		n    int
+		want time.Duration
+	}{
+		{n: 1, want: 100 * time.Millisecond},
+		{n: 2, want: 200 * time.Millisecond},
+		{n: 3, want: 400 * time.Millisecond},
+		{n: 4, want: 800 * time.Millisecond},
+		{n: 5, want: time.Second},
+		{n: 50, want: time.Second},
+	}
+
+	for _, tc := range cases {
+		for i := 0; i < 20; i++ {
+			if got := p.backoff(tc.n); got < tc.want/2 || got > tc.want {
+				t.Fatalf("got %v for retry %v; want between %v and %v", got, tc.n, tc.want/2, tc.want)
+			}
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2018, 11, 28, 10, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		value string
+		want  time.Duration
+	}{
+		{value: "", want: 0},
+		{value: "120", want: 2 * time.Minute},
+		{value: "Wed, 28 Nov 2018 10:00:30 GMT", want: 30 * time.Second},
+		{value: "Wed, 28 Nov 2018 09:00:00 GMT", want: 0},
+		{value: "soon", want: 0},
+	}
+
+	for _, tc := range cases {
+		res := &http.Response{Header: http.Header{}}
+		if tc.value != "" {
+			res.Header.Set("Retry-After", tc.value)
+		}
+		if got := retryAfter(res, now); got != tc.want {
+			t.Fatalf("got %v for %q; want %v", got, tc.value, tc.want)
+		}
+	}
+}
+
+func TestParseOptions_Retry(t *testing.T) {
+	cases := []struct {
+		name    string
+		conf    map[string]string
+		want    RetryPolicy
+		wantErr bool
+	}{
+		{name: "default", conf: map[string]string{}, want: DefaultRetryPolicy},
+		{
+			name: "configured",
+			conf: map[string]string{"TEST_MAX_RETRIES": "5", "TEST_RETRY_BACKOFF": "1s", "TEST_RETRY_MAX_BACKOFF": "1m"},
+			want: RetryPolicy{MaxRetries: 5, Backoff: time.Second, MaxBackoff: time.Minute},
+		},
+		{name: "disabled", conf: map[string]string{"TEST_MAX_RETRIES": "0"}, want: RetryPolicy{Backoff: DefaultRetryPolicy.Backoff, MaxBackoff: DefaultRetryPolicy.MaxBackoff}},
+		{name: "invalid", conf: map[string]string{"TEST_RETRY_BACKOFF": "soon"}, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			o, err := ParseOptions(Config(func(key string) string { return tc.conf[key] }), "TEST_")
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("got nil; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("ParseOptions failed: ", err)
+			}
+			if o.Retry != tc.want {
+				t.Fatalf("got %v; want %v", o.Retry, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package blogservice
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExcerptLength is the max length in runes of an excerpt
+const ExcerptLength = 200
+
+var (
+	tagPattern   = regexp.MustCompile(`<[^>]*>`)
+	imagePattern = regexp.MustCompile(`<img[^>]+src="([^"]+)"`)
+)
+
+// Excerpt returns text stripped of html tags and collapsed whitespace, truncated to ExcerptLength runes
+func Excerpt(text string) string {
+	text = html.UnescapeString(tagPattern.ReplaceAllString(text, " "))
+	text = strings.Join(strings.Fields(text), " ")
+	if utf8.RuneCountInString(text) <= ExcerptLength {
+		return text
+	}
+	return string([]rune(text)[:ExcerptLength]) + "…"
+}
+
+// FirstImage returns the src of the first img element of html, or empty string if there is none
+func FirstImage(html string) string {
+	m := imagePattern.FindStringSubmatch(html)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
@@ -0,0 +1,36 @@
+package blogservice
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcerpt(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "hello world", want: "hello world"},
+		{name: "html", text: "<h1>Title</h1>\n<p>hello &amp; <b>world</b></p>", want: "Title hello & world"},
+		{name: "truncated", text: strings.Repeat("あ", ExcerptLength+1), want: strings.Repeat("あ", ExcerptLength) + "…"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Excerpt(tc.text); got != tc.want {
+				t.Fatalf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFirstImage(t *testing.T) {
+	html := `<p>text</p><img alt="a" src="https://example.com/a.png"><img src="https://example.com/b.png">`
+	if got, want := FirstImage(html), "https://example.com/a.png"; got != want {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+	if got := FirstImage("<p>no image</p>"); got != "" {
+		t.Fatalf("got %v; want empty", got)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"container/list"
 	"encoding/base64"
 	"fmt"
 	"strings"
@@ -10,46 +11,201 @@ import (
 	"github.com/shiimaxx/blog-aggregator/structs"
 )
 
+// state is the freshness of a cached content
+type state int
+
+const (
+	// missing means the content is not cached or expired beyond the max stale
+	missing state = iota
+	// stale means the content is expired but still can be served while it is revalidated
+	stale
+	// fresh means the content is not expired
+	fresh
+)
+
+func (s state) String() string {
+	switch s {
+	case fresh:
+		return "fresh"
+	case stale:
+		return "stale"
+	default:
+		return "missing"
+	}
+}
+
+// storage caches contents. A content is fresh for the duration given to Set,
+// and then stale for the max stale of the storage.
+// Get returns the content, the time when it gets stale and its state.
+// Contents are shared by the callers of Get, so they must not be modified after Set.
 type storage interface {
-	Get(key string) []structs.Entry
+	Get(key string) ([]structs.Entry, time.Time, state)
 	Set(key string, content []structs.Entry, duration time.Duration)
+	Close() error
+}
+
+// locker is implemented by storages shared between processes,
+// so that one of the processes refreshes a content at once
+type locker interface {
+	// TryLock takes the lock of key for ttl unless another process holds it, and reports whether it is taken
+	TryLock(key string, ttl time.Duration) bool
+}
+
+// freshness returns the state at now of a content which expires at expiration
+func freshness(expiration, now int64, maxStale time.Duration) state {
+	switch {
+	case now > expiration+int64(maxStale):
+		return missing
+	case now > expiration:
+		return stale
+	default:
+		return fresh
+	}
 }
 
 type item struct {
 	content    []structs.Entry
 	expiration int64
+	elem       *list.Element
+}
+
+// storedItem is the json representation of a cached item in an external storage
+type storedItem struct {
+	Key        string          `json:"key"`
+	Expiration time.Time       `json:"expiration"`
+	Content    []structs.Entry `json:"content"`
 }
 
+// memStorage is a storage in memory.
+// It holds up to maxEntries items and evicts the least recently used one when it is full.
+// Zero maxEntries means no limit.
 type memStorage struct {
-	items map[string]item
-	mu    *sync.RWMutex
+	items      map[string]item
+	mu         *sync.RWMutex
+	maxStale   time.Duration
+	maxEntries int
+
+	// lru lists the keys from the most recently used one
+	lru *list.List
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newMemStorage returns a memStorage whose janitor purges expired items every cleanupInterval.
+// Zero cleanupInterval disables the janitor.
+func newMemStorage(maxStale time.Duration, maxEntries int, cleanupInterval time.Duration) *memStorage {
+	m := &memStorage{
+		items:      make(map[string]item),
+		mu:         &sync.RWMutex{},
+		maxStale:   maxStale,
+		maxEntries: maxEntries,
+	}
+	if cleanupInterval > 0 {
+		m.stop = make(chan struct{})
+		m.done = make(chan struct{})
+		go m.janitor(cleanupInterval)
+	}
+	return m
 }
 
 func GenerateCacheKey(url, service string) string {
 	return fmt.Sprintf("ba:%s:", service) + strings.TrimRight(base64.URLEncoding.EncodeToString([]byte(url)), "=")
 }
 
-func (m *memStorage) Get(key string) []structs.Entry {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+func (m *memStorage) Get(key string) ([]structs.Entry, time.Time, state) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
 	i, ok := m.items[key]
 	if !ok {
-		return nil
+		return nil, time.Time{}, missing
 	}
-	if time.Now().UnixNano() > i.expiration {
-		return nil
+	st := freshness(i.expiration, time.Now().UnixNano(), m.maxStale)
+	if st == missing {
+		m.delete(key)
+		return nil, time.Time{}, missing
+	}
+	if i.elem != nil {
+		m.lru.MoveToFront(i.elem)
 	}
 
-	return i.content
+	return i.content, time.Unix(0, i.expiration), st
 }
 
 func (m *memStorage) Set(key string, content []structs.Entry, duration time.Duration) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.lru == nil {
+		m.lru = list.New()
+	}
+	elem := m.items[key].elem
+	if elem != nil {
+		m.lru.MoveToFront(elem)
+	} else {
+		elem = m.lru.PushFront(key)
+	}
 	m.items[key] = item{
 		content:    content,
 		expiration: time.Now().Add(duration).UnixNano(),
+		elem:       elem,
+	}
+
+	for m.maxEntries > 0 && len(m.items) > m.maxEntries {
+		m.delete(m.lru.Back().Value.(string))
+	}
+}
+
+// DeleteExpired purges the items expired beyond the max stale.
+// Stale items are kept since they still can be served.
+func (m *memStorage) DeleteExpired() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UnixNano()
+	for key, i := range m.items {
+		if freshness(i.expiration, now, m.maxStale) == missing {
+			m.delete(key)
+		}
+	}
+}
+
+// Close stops the janitor
+func (m *memStorage) Close() error {
+	if m.stop == nil {
+		return nil
+	}
+	m.mu.Lock()
+	select {
+	case <-m.stop:
+	default:
+		close(m.stop)
+	}
+	m.mu.Unlock()
+	<-m.done
+	return nil
+}
+
+func (m *memStorage) janitor(interval time.Duration) {
+	defer close(m.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			m.DeleteExpired()
+		}
+	}
+}
+
+// delete removes the item of key. m.mu must be held.
+func (m *memStorage) delete(key string) {
+	if elem := m.items[key].elem; elem != nil {
+		m.lru.Remove(elem)
 	}
+	delete(m.items, key)
 }
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"reflect"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -49,7 +51,7 @@ func TestMemStorage_Get(t *testing.T) {
 
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
-			c := cache.Get(tc.key)
+			c, _, _ := cache.Get(tc.key)
 			if !reflect.DeepEqual(c, tc.want) {
 				t.Fatalf("got %v; want %v", c, tc.want)
 			}
@@ -58,7 +60,7 @@ func TestMemStorage_Get(t *testing.T) {
 }
 
 func TestMemStorage_Set(t *testing.T) {
-	c := cache.Get("4")
+	c, _, _ := cache.Get("4")
 	if c != nil {
 		t.Fatalf("got %v; want nil", c)
 	}
@@ -69,13 +71,160 @@ func TestMemStorage_Set(t *testing.T) {
 		{Title: "e", URL: "https://example.com/e", CreatedAt: now.Add(11 * time.Hour)},
 	}, 60*time.Second)
 
-	cc := cache.Get("4")
+	cc, _, _ := cache.Get("4")
 	if cc == nil {
 		t.Fatal("got nil; want not nil")
 	}
 
 }
 
+func TestMemStorage_State(t *testing.T) {
+	m := &memStorage{
+		items:    make(map[string]item),
+		mu:       &sync.RWMutex{},
+		maxStale: 60 * time.Second,
+	}
+	content := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+	}
+	m.Set("fresh", content, 60*time.Second)
+	m.Set("stale", content, -30*time.Second)
+	m.Set("expired", content, -90*time.Second)
+	m.Set("empty", []structs.Entry{}, 60*time.Second)
+
+	cases := []struct {
+		key       string
+		wantState state
+		wantLen   int
+	}{
+		{key: "fresh", wantState: fresh, wantLen: 1},
+		{key: "stale", wantState: stale, wantLen: 1},
+		{key: "expired", wantState: missing, wantLen: 0},
+		{key: "empty", wantState: fresh, wantLen: 0},
+		{key: "unknown", wantState: missing, wantLen: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.key, func(t *testing.T) {
+			c, _, st := m.Get(tc.key)
+			if st != tc.wantState {
+				t.Fatalf("got %v; want %v", st, tc.wantState)
+			}
+			if len(c) != tc.wantLen {
+				t.Fatalf("got %v; want %v entries", c, tc.wantLen)
+			}
+		})
+	}
+}
+
+func TestMemStorage_Eviction(t *testing.T) {
+	content := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+	}
+
+	cases := []struct {
+		name       string
+		maxEntries int
+		ops        []string
+		want       []string
+	}{
+		{name: "no limit", maxEntries: 0, ops: []string{"set 1", "set 2", "set 3"}, want: []string{"1", "2", "3"}},
+		{name: "within limit", maxEntries: 3, ops: []string{"set 1", "set 2", "set 3"}, want: []string{"1", "2", "3"}},
+		{name: "oldest is evicted", maxEntries: 2, ops: []string{"set 1", "set 2", "set 3"}, want: []string{"2", "3"}},
+		{name: "get keeps item", maxEntries: 2, ops: []string{"set 1", "set 2", "get 1", "set 3"}, want: []string{"1", "3"}},
+		{name: "set keeps item", maxEntries: 2, ops: []string{"set 1", "set 2", "set 1", "set 3"}, want: []string{"1", "3"}},
+		{name: "overwrite does not evict", maxEntries: 2, ops: []string{"set 1", "set 2", "set 2", "set 2"}, want: []string{"1", "2"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMemStorage(time.Minute, tc.maxEntries, 0)
+			defer m.Close()
+			for _, op := range tc.ops {
+				var name, key string
+				fmt.Sscan(op, &name, &key)
+				switch name {
+				case "set":
+					m.Set(key, content, time.Minute)
+				case "get":
+					m.Get(key)
+				}
+			}
+
+			var keys []string
+			for key := range m.items {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tc.want) {
+				t.Fatalf("got %v; want %v", keys, tc.want)
+			}
+			if got, want := m.lru.Len(), len(tc.want); got != want {
+				t.Fatalf("got %v; want %v lru elements", got, want)
+			}
+		})
+	}
+}
+
+func TestMemStorage_DeleteExpired(t *testing.T) {
+	content := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+	}
+
+	cases := []struct {
+		name       string
+		expiration time.Duration
+		wantKept   bool
+	}{
+		{name: "fresh", expiration: 60 * time.Second, wantKept: true},
+		{name: "stale", expiration: -30 * time.Second, wantKept: true},
+		{name: "expired", expiration: -90 * time.Second, wantKept: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMemStorage(60*time.Second, 0, 0)
+			defer m.Close()
+			m.Set(tc.name, content, tc.expiration)
+
+			m.DeleteExpired()
+
+			if _, ok := m.items[tc.name]; ok != tc.wantKept {
+				t.Fatalf("got %v; want %v", ok, tc.wantKept)
+			}
+			if got, want := m.lru.Len(), len(m.items); got != want {
+				t.Fatalf("got %v; want %v lru elements", got, want)
+			}
+		})
+	}
+}
+
+func TestMemStorage_Janitor(t *testing.T) {
+	m := newMemStorage(0, 0, 10*time.Millisecond)
+	m.Set("expired", []structs.Entry{}, -time.Second)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		m.mu.RLock()
+		n := len(m.items)
+		m.mu.RUnlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired item is not purged")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := m.Close(); err != nil {
+		t.Fatal("Close failed: ", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatal("second Close failed: ", err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	cache.Set("1", []structs.Entry{
 		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
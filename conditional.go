@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// strongETag returns the strong entity tag of the representation of f encoded by enc.
+// The statuses of the sources are left out of the hashed representation,
+// so that it changes only when the page does.
+func strongETag(enc encoder, f *feed) (string, error) {
+	page := *f
+	page.sources = nil
+	h := sha1.New()
+	if err := enc.encode(h, &page); err != nil {
+		return "", err
+	}
+	return `"` + hex.EncodeToString(h.Sum(nil)) + `"`, nil
+}
+
+// notModified reports whether the client has the representation of etag by the If-None-Match header of r.
+// If-Modified-Since is not enough, since the Last-Modified of the newest entry
+// does not change when entries are deleted or left out of the timeline.
+func notModified(r *http.Request, etag string) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return false
+	}
+	for _, t := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || t != "" && strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl returns the Cache-Control header value which lets clients reuse
+// a response until expiration
+func cacheControl(expiration time.Time, now time.Time) string {
+	maxAge := int64(expiration.Sub(now) / time.Second)
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	return fmt.Sprintf("public, max-age=%d", maxAge)
+}
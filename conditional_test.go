@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	etag := `"abc"`
+
+	cases := []struct {
+		name   string
+		method string
+		header map[string]string
+		want   bool
+	}{
+		{name: "no condition", want: false},
+		{name: "etag matches", header: map[string]string{"If-None-Match": `"abc"`}, want: true},
+		{name: "etag in list", header: map[string]string{"If-None-Match": `"xyz", "abc"`}, want: true},
+		{name: "weak etag matches", header: map[string]string{"If-None-Match": `W/"abc"`}, want: true},
+		{name: "any etag", header: map[string]string{"If-None-Match": "*"}, want: true},
+		{name: "etag differs", header: map[string]string{"If-None-Match": `"xyz"`}, want: false},
+		{name: "etag precedes date", header: map[string]string{"If-None-Match": `"xyz"`, "If-Modified-Since": "Wed, 28 Nov 2018 10:00:00 GMT"}, want: false},
+		{name: "etag matches with date", header: map[string]string{"If-None-Match": `"abc"`, "If-Modified-Since": "Wed, 28 Nov 2018 09:00:00 GMT"}, want: true},
+		{name: "date alone", header: map[string]string{"If-Modified-Since": "Wed, 28 Nov 2018 10:00:00 GMT"}, want: false},
+		{name: "empty etag", header: map[string]string{"If-None-Match": ","}, want: false},
+		{name: "post", method: "POST", header: map[string]string{"If-None-Match": `"abc"`}, want: false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			method := tc.method
+			if method == "" {
+				method = "GET"
+			}
+			req, _ := http.NewRequest(method, "/api/v1/entries", nil)
+			for k, v := range tc.header {
+				req.Header.Set(k, v)
+			}
+			if got := notModified(req, etag); got != tc.want {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCacheControl(t *testing.T) {
+	cases := []struct {
+		expiration time.Duration
+		want       string
+	}{
+		{expiration: 90 * time.Second, want: "public, max-age=90"},
+		{expiration: 500 * time.Millisecond, want: "public, max-age=0"},
+		{expiration: -30 * time.Second, want: "public, max-age=0"},
+	}
+
+	for _, tc := range cases {
+		if got := cacheControl(now.Add(tc.expiration), now); got != tc.want {
+			t.Fatalf("got %v, want %v", got, tc.want)
+		}
+	}
+}
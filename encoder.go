@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+const defaultFeedTitle = "blog-aggregator"
+
+// feed is the data rendered by encoders
+type feed struct {
+	self  *url.URL
+	title string
+	link  string
+	// entries is the requested page of the timeline
+	entries []structs.Entry
+	// total is the number of entries in the timeline
+	total int
+	next  string
+	prev  string
+	// lastModified is the newest updated time of the timeline
+	lastModified time.Time
+	sources      []blogservice.Source
+}
+
+// encoder renders a feed in a response format
+type encoder struct {
+	format    string
+	mediaType string
+	encode    func(w io.Writer, f *feed) error
+}
+
+// encoders are the response formats of the entries endpoint. The first one is the default.
+var encoders = []encoder{
+	{format: "json", mediaType: "application/json", encode: encodeJSON},
+	{format: "atom", mediaType: "application/atom+xml", encode: encodeAtom},
+	{format: "rss", mediaType: "application/rss+xml", encode: encodeRSS},
+	{format: "jsonfeed", mediaType: "application/feed+json", encode: encodeJSONFeed},
+}
+
+func lookupEncoder(format string) (encoder, bool) {
+	for _, e := range encoders {
+		if e.format == format {
+			return e, true
+		}
+	}
+	return encoder{}, false
+}
+
+// selectEncoder selects the encoder by the format query parameter, the path extension
+// or the Accept header of r in this order
+func selectEncoder(r *http.Request) (encoder, error) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = strings.TrimPrefix(path.Ext(r.URL.Path), ".")
+	}
+	if format != "" {
+		e, ok := lookupEncoder(format)
+		if !ok {
+			return encoder{}, fmt.Errorf("unsupported format: %s", format)
+		}
+		return e, nil
+	}
+
+	offers := make([]string, len(encoders))
+	for i, e := range encoders {
+		offers[i] = e.mediaType
+	}
+	mediaType := negotiate(r, offers...)
+	for _, e := range encoders {
+		if e.mediaType == mediaType {
+			return e, nil
+		}
+	}
+	return encoders[0], nil
+}
+
+// requestURL returns the absolute url of r
+func requestURL(r *http.Request) *url.URL {
+	u := *r.URL
+	u.Host = r.Host
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		u.Scheme = proto
+	}
+	return &u
+}
+
+// negotiate returns the offer which best matches the Accept header of r.
+// It returns the first offer if r has no Accept header, and empty string if no offer is acceptable.
+func negotiate(r *http.Request, offers ...string) string {
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return offers[0]
+	}
+
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		for _, spec := range strings.Split(accept, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(spec))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil {
+					continue
+				}
+			}
+			if !matchMediaType(mediaType, offer) || q <= bestQ {
+				continue
+			}
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+func matchMediaType(pattern, mediaType string) bool {
+	if pattern == "*/*" || pattern == mediaType {
+		return true
+	}
+	return strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*"))
+}
+
+func encodeJSON(w io.Writer, f *feed) error {
+	var res entriesResponse
+	res.Entries = f.entries
+	res.Total = f.total
+	res.Next = f.next
+	res.Prev = f.prev
+	res.Sources = newSourceStatuses(f.sources)
+	return json.NewEncoder(w).Encode(res)
+}
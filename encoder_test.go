@@ -0,0 +1,74 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	offers := []string{"application/json", "application/atom+xml"}
+	cases := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{name: "no accept", accept: "", want: "application/json"},
+		{name: "any", accept: "*/*", want: "application/json"},
+		{name: "atom", accept: "application/atom+xml", want: "application/atom+xml"},
+		{name: "atom preferred", accept: "application/json;q=0.5, application/atom+xml", want: "application/atom+xml"},
+		{name: "wildcard subtype", accept: "application/*", want: "application/json"},
+		{name: "not acceptable", accept: "text/html", want: ""},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+			if err != nil {
+				t.Fatal("NewRequest failed: ", err.Error())
+			}
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			if got := negotiate(req, offers...); got != tc.want {
+				t.Fatalf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectEncoder(t *testing.T) {
+	cases := []struct {
+		name    string
+		url     string
+		accept  string
+		want    string
+		wantErr bool
+	}{
+		{name: "default", url: "/api/v1/entries", want: "json"},
+		{name: "extension", url: "/api/v1/entries.rss", want: "rss"},
+		{name: "format parameter", url: "/api/v1/entries?format=jsonfeed", want: "jsonfeed"},
+		{name: "format parameter precedes extension", url: "/api/v1/entries.rss?format=atom", want: "atom"},
+		{name: "accept header", url: "/api/v1/entries", accept: "application/rss+xml", want: "rss"},
+		{name: "not acceptable", url: "/api/v1/entries", accept: "text/html", want: "json"},
+		{name: "unsupported format", url: "/api/v1/entries?format=csv", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tc.url, nil)
+			if err != nil {
+				t.Fatal("NewRequest failed: ", err.Error())
+			}
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			e, err := selectEncoder(req)
+			if got := err != nil; got != tc.wantErr {
+				t.Fatalf("got error %v; want error %v", err, tc.wantErr)
+			}
+			if got := e.format; got != tc.want {
+				t.Fatalf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+	"golang.org/x/tools/blog/atom"
+)
+
+// updated returns the newest updated time of the entries,
+// or of the timeline if the page has no entries
+func (f *feed) updated() time.Time {
+	var updated time.Time
+	for _, e := range f.entries {
+		if u := updatedAt(e); u.After(updated) {
+			updated = u
+		}
+	}
+	if updated.IsZero() {
+		return f.lastModified
+	}
+	return updated
+}
+
+// pagingParams are the query parameters which select a page of the timeline
+var pagingParams = []string{"cursor", "offset", "limit"}
+
+// id returns the self url without the paging parameters, so that every page of the feed has the same id
+func (f *feed) id() string {
+	u := *f.self
+	q := u.Query()
+	for _, p := range pagingParams {
+		q.Del(p)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// updatedAt returns the updated time of e, or the created time if e has never been updated
+func updatedAt(e structs.Entry) time.Time {
+	if e.UpdatedAt.IsZero() {
+		return e.CreatedAt
+	}
+	return e.UpdatedAt
+}
+
+func encodeAtom(w io.Writer, f *feed) error {
+	feed := &atom.Feed{
+		Title: f.title,
+		ID:    f.id(),
+		Link: []atom.Link{
+			{Rel: "self", Href: f.self.String(), Type: "application/atom+xml"},
+		},
+		Updated: atom.Time(f.updated()),
+		Author:  &atom.Person{Name: f.title},
+	}
+	if f.link != "" {
+		feed.Link = append(feed.Link, atom.Link{Rel: "alternate", Href: f.link, Type: "text/html"})
+	}
+	if f.next != "" {
+		feed.Link = append(feed.Link, atom.Link{Rel: "next", Href: f.next, Type: "application/atom+xml"})
+	}
+	if f.prev != "" {
+		feed.Link = append(feed.Link, atom.Link{Rel: "previous", Href: f.prev, Type: "application/atom+xml"})
+	}
+
+	for _, e := range f.entries {
+		entry := &atom.Entry{
+			Title: e.Title,
+			ID:    e.ID,
+			Link: []atom.Link{
+				{Rel: "alternate", Href: e.URL, Type: "text/html"},
+			},
+			Published: atom.Time(e.CreatedAt),
+			Updated:   atom.Time(updatedAt(e)),
+		}
+		if e.Author != "" {
+			entry.Author = &atom.Person{Name: e.Author}
+		}
+		if e.Summary != "" {
+			entry.Summary = &atom.Text{Type: "text", Body: e.Summary}
+		}
+		feed.Entry = append(feed.Entry, entry)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(feed)
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string      `xml:"title"`
+	Link          string      `xml:"link"`
+	Description   string      `xml:"description"`
+	AtomLink      rssAtomLink `xml:"atom:link"`
+	LastBuildDate string      `xml:"lastBuildDate"`
+	Items         []rssItem   `xml:"item"`
+}
+
+type rssAtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description,omitempty"`
+	Categories  []string `xml:"category"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+func encodeRSS(w io.Writer, f *feed) error {
+	link := f.link
+	if link == "" {
+		link = f.self.String()
+	}
+
+	feed := rss{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:         f.title,
+			Link:          link,
+			Description:   f.title,
+			AtomLink:      rssAtomLink{Href: f.self.String(), Rel: "self", Type: "application/rss+xml"},
+			LastBuildDate: f.updated().Format(time.RFC1123Z),
+		},
+	}
+
+	for _, e := range f.entries {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       e.Title,
+			Link:        e.URL,
+			Description: e.Summary,
+			Categories:  e.Tags,
+			GUID:        rssGUID{IsPermaLink: true, Value: e.URL},
+			PubDate:     e.CreatedAt.Format(time.RFC1123Z),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(feed)
+}
+
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url"`
+	NextURL     string         `json:"next_url,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	Title         string           `json:"title"`
+	ContentText   string           `json:"content_text"`
+	Summary       string           `json:"summary,omitempty"`
+	Image         string           `json:"image,omitempty"`
+	DatePublished string           `json:"date_published"`
+	DateModified  string           `json:"date_modified"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+func encodeJSONFeed(w io.Writer, f *feed) error {
+	feed := jsonFeed{
+		Version:     jsonFeedVersion,
+		Title:       f.title,
+		HomePageURL: f.link,
+		FeedURL:     f.self.String(),
+		NextURL:     f.next,
+		Items:       []jsonFeedItem{},
+	}
+
+	for _, e := range f.entries {
+		item := jsonFeedItem{
+			ID:            e.ID,
+			URL:           e.URL,
+			Title:         e.Title,
+			ContentText:   e.Title,
+			Summary:       e.Summary,
+			Image:         e.Thumbnail,
+			DatePublished: e.CreatedAt.Format(time.RFC3339),
+			DateModified:  updatedAt(e).Format(time.RFC3339),
+			Tags:          e.Tags,
+		}
+		if e.Summary != "" {
+			item.ContentText = e.Summary
+		}
+		if e.Author != "" {
+			item.Authors = []jsonFeedAuthor{{Name: e.Author}}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+
+	return json.NewEncoder(w).Encode(feed)
+}
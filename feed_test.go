@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+	"golang.org/x/tools/blog/atom"
+)
+
+var feedData = []structs.Entry{
+	{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+	{Title: "b", URL: "https://example.com/b", CreatedAt: now.Add(1 * time.Hour)},
+	{ID: "stub:c", Source: "stub", Title: "c", URL: "https://example.com/c", Summary: "summary of c", Tags: []string{"go"}, CreatedAt: now.Add(2 * time.Hour), UpdatedAt: now.Add(3 * time.Hour)},
+}
+
+// serveFeed requests the entries endpoint of a server which provides feedData
+func serveFeed(t *testing.T, path, accept string) *httptest.ResponseRecorder {
+	req, err := http.NewRequest("GET", path, nil)
+	if err != nil {
+		t.Fatal("NewRequest failed: ", err.Error())
+	}
+	req.Host = "example.com"
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+
+	rec := httptest.NewRecorder()
+
+	s := server{
+		logger: log.New(os.Stdout, "", log.Lshortfile),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	s.blogService.Add(&stubProvider{name: "stub:testuser", entries: feedData})
+
+	handler := s.handleEntries()
+
+	handler.ServeHTTP(rec, req)
+
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	return rec
+}
+
+func TestHandleEntries_Atom(t *testing.T) {
+	cases := []struct {
+		name        string
+		path        string
+		accept      string
+		wantID      string
+		wantEntries int
+	}{
+		{name: "extension", path: "/api/v1/entries.atom", wantID: "http://example.com/api/v1/entries.atom", wantEntries: 3},
+		{name: "accept header", path: "/api/v1/entries", accept: "application/atom+xml", wantID: "http://example.com/api/v1/entries", wantEntries: 3},
+		{name: "page", path: "/api/v1/entries.atom?limit=1&offset=0&tag=go", wantID: "http://example.com/api/v1/entries.atom?tag=go", wantEntries: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := serveFeed(t, tc.path, tc.accept)
+
+			if got, want := rec.HeaderMap.Get("Content-Type"), "application/atom+xml; charset=utf-8"; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+
+			var feed atom.Feed
+			if err := xml.NewDecoder(rec.Body).Decode(&feed); err != nil {
+				t.Fatal("xml Decode failed: ", err)
+			}
+			if got, want := feed.ID, tc.wantID; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := feed.Updated, atom.Time(now.Add(3*time.Hour)); got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := len(feed.Entry), tc.wantEntries; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+			if got, want := feed.Entry[0].ID, "stub:c"; got != want {
+				t.Fatalf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHandleEntries_RSS(t *testing.T) {
+	rec := serveFeed(t, "/api/v1/entries.rss", "")
+
+	if got, want := rec.HeaderMap.Get("Content-Type"), "application/rss+xml; charset=utf-8"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	var feed rss
+	if err := xml.NewDecoder(rec.Body).Decode(&feed); err != nil {
+		t.Fatal("xml Decode failed: ", err)
+	}
+	if got, want := feed.Version, "2.0"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := len(feed.Channel.Items), len(feedData); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	item := feed.Channel.Items[0]
+	if got, want := item.Link, "https://example.com/c"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := item.PubDate, now.Add(2*time.Hour).Format(time.RFC1123Z); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := item.Categories, []string{"go"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestHandleEntries_JSONFeed(t *testing.T) {
+	rec := serveFeed(t, "/api/v1/entries?format=jsonfeed", "")
+
+	if got, want := rec.HeaderMap.Get("Content-Type"), "application/feed+json; charset=utf-8"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	var feed jsonFeed
+	if err := json.NewDecoder(rec.Body).Decode(&feed); err != nil {
+		t.Fatal("json Decode failed: ", err)
+	}
+	if got, want := feed.Version, jsonFeedVersion; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := feed.FeedURL, "http://example.com/api/v1/entries?format=jsonfeed"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := len(feed.Items), len(feedData); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := feed.Items[0].ID, "stub:c"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := feed.Items[0].ContentText, "summary of c"; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
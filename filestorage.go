@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+const fileStorageExt = ".json"
+
+// fileStorage is a storage which keeps each item as a json file in dir,
+// so that the cache survives restarts
+type fileStorage struct {
+	dir      string
+	mu       sync.RWMutex
+	maxStale time.Duration
+	logger   *log.Logger
+}
+
+// newFileStorage returns a fileStorage in dir. dir is created if it does not exist,
+// and the items expired beyond maxStale are purged.
+func newFileStorage(dir string, maxStale time.Duration, logger *log.Logger) (*fileStorage, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	f := &fileStorage{
+		dir:      dir,
+		maxStale: maxStale,
+		logger:   logger,
+	}
+	if err := f.DeleteExpired(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *fileStorage) Get(key string) ([]structs.Entry, time.Time, state) {
+	f.mu.RLock()
+	i, err := f.read(f.path(key))
+	f.mu.RUnlock()
+	if err != nil {
+		if !os.IsNotExist(err) {
+			f.logger.Printf("[ERROR] %s %s", key, err.Error())
+		}
+		return nil, time.Time{}, missing
+	}
+
+	st := freshness(i.Expiration.UnixNano(), time.Now().UnixNano(), f.maxStale)
+	if st == missing {
+		f.remove(key)
+		return nil, time.Time{}, missing
+	}
+	return i.Content, i.Expiration, st
+}
+
+// remove removes the item of key unless it has been set again since it expired
+func (f *fileStorage) remove(key string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	i, err := f.read(f.path(key))
+	if err == nil && freshness(i.Expiration.UnixNano(), time.Now().UnixNano(), f.maxStale) != missing {
+		return
+	}
+	os.Remove(f.path(key))
+}
+
+func (f *fileStorage) Set(key string, content []structs.Entry, duration time.Duration) {
+	if content == nil {
+		content = []structs.Entry{}
+	}
+	i := storedItem{
+		Key:        key,
+		Expiration: time.Now().Add(duration),
+		Content:    content,
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.write(f.path(key), &i); err != nil {
+		f.logger.Printf("[ERROR] %s %s", key, err.Error())
+	}
+}
+
+// DeleteExpired removes the items expired beyond the max stale.
+// Stale items are kept since they still can be served.
+func (f *fileStorage) DeleteExpired() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	paths, err := filepath.Glob(filepath.Join(f.dir, "*"+fileStorageExt))
+	if err != nil {
+		return err
+	}
+	now := time.Now().UnixNano()
+	for _, path := range paths {
+		i, err := f.read(path)
+		if err != nil || freshness(i.Expiration.UnixNano(), now, f.maxStale) == missing {
+			os.Remove(path)
+		}
+	}
+	return nil
+}
+
+// Close does nothing since every item is written through to the file
+func (f *fileStorage) Close() error {
+	return nil
+}
+
+// path returns the file path of key. key is encoded since it can contain any characters.
+func (f *fileStorage) path(key string) string {
+	return filepath.Join(f.dir, base64.RawURLEncoding.EncodeToString([]byte(key))+fileStorageExt)
+}
+
+func (f *fileStorage) read(path string) (*storedItem, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var i storedItem
+	if err := json.Unmarshal(data, &i); err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// write writes i to a temporary file and renames it to path,
+// so that readers never see a partially written item
+func (f *fileStorage) write(path string, i *storedItem) error {
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(f.dir, strings.TrimSuffix(filepath.Base(path), fileStorageExt)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+func newTestFileStorage(t *testing.T, maxStale time.Duration) (*fileStorage, func()) {
+	dir, err := ioutil.TempDir("", "blog-aggregator")
+	if err != nil {
+		t.Fatal("TempDir failed: ", err)
+	}
+	f, err := newFileStorage(dir, maxStale, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal("newFileStorage failed: ", err)
+	}
+	return f, func() { os.RemoveAll(dir) }
+}
+
+func TestFileStorage_State(t *testing.T) {
+	f, teardown := newTestFileStorage(t, 60*time.Second)
+	defer teardown()
+
+	content := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+	}
+	f.Set("fresh", content, 60*time.Second)
+	f.Set("stale", content, -30*time.Second)
+	f.Set("expired", content, -90*time.Second)
+	f.Set("empty", []structs.Entry{}, 60*time.Second)
+
+	cases := []struct {
+		key       string
+		wantState state
+		wantLen   int
+	}{
+		{key: "fresh", wantState: fresh, wantLen: 1},
+		{key: "stale", wantState: stale, wantLen: 1},
+		{key: "expired", wantState: missing, wantLen: 0},
+		{key: "empty", wantState: fresh, wantLen: 0},
+		{key: "unknown", wantState: missing, wantLen: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.key, func(t *testing.T) {
+			c, _, st := f.Get(tc.key)
+			if st != tc.wantState {
+				t.Fatalf("got %v; want %v", st, tc.wantState)
+			}
+			if len(c) != tc.wantLen {
+				t.Fatalf("got %v; want %v entries", c, tc.wantLen)
+			}
+		})
+	}
+
+	if _, err := os.Stat(f.path("expired")); !os.IsNotExist(err) {
+		t.Fatalf("got %v; want expired item removed", err)
+	}
+}
+
+func TestFileStorage_Reopen(t *testing.T) {
+	f, teardown := newTestFileStorage(t, 60*time.Second)
+	defer teardown()
+
+	content := []structs.Entry{
+		{
+			ID:        "stub:1",
+			Source:    "stub",
+			Title:     "a",
+			URL:       "https://example.com/a",
+			Tags:      []string{"go"},
+			CreatedAt: now.UTC().Truncate(time.Second),
+			UpdatedAt: now.UTC().Truncate(time.Second),
+		},
+	}
+	key := GenerateCacheKey("stub:testuser", "stub")
+	f.Set(key, content, 60*time.Second)
+	f.Set("stale", content, -30*time.Second)
+	f.Set("expired", content, -90*time.Second)
+	if err := f.Close(); err != nil {
+		t.Fatal("Close failed: ", err)
+	}
+
+	reopened, err := newFileStorage(f.dir, 60*time.Second, f.logger)
+	if err != nil {
+		t.Fatal("newFileStorage failed: ", err)
+	}
+
+	c, _, st := reopened.Get(key)
+	if st != fresh {
+		t.Fatalf("got %v; want %v", st, fresh)
+	}
+	if !reflect.DeepEqual(c, content) {
+		t.Fatalf("got %v; want %v", c, content)
+	}
+	if _, _, st := reopened.Get("stale"); st != stale {
+		t.Fatalf("got %v; want %v", st, stale)
+	}
+
+	paths, _ := filepath.Glob(filepath.Join(f.dir, "*"))
+	if got, want := len(paths), 2; got != want {
+		t.Fatalf("got %v; want %v files", paths, want)
+	}
+}
+
+func TestFileStorage_Corrupted(t *testing.T) {
+	f, teardown := newTestFileStorage(t, 60*time.Second)
+	defer teardown()
+
+	if err := ioutil.WriteFile(f.path("broken"), []byte("{"), 0644); err != nil {
+		t.Fatal("WriteFile failed: ", err)
+	}
+	if c, _, st := f.Get("broken"); st != missing || c != nil {
+		t.Fatalf("got %v %v; want missing", c, st)
+	}
+
+	f.Set("broken", []structs.Entry{{Title: "a"}}, 60*time.Second)
+	if c, _, st := f.Get("broken"); st != fresh || len(c) != 1 {
+		t.Fatalf("got %v %v; want fresh", c, st)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// filter narrows the timeline by the query parameters of the entries endpoint
+type filter struct {
+	// sources matches entries of any of the blog service kinds
+	sources []string
+	// tags matches entries tagged with any of the tags
+	tags []string
+	// since matches entries created at or after it
+	since time.Time
+	// until matches entries created before it
+	until time.Time
+	// keywords matches entries whose title or summary contains all of them
+	keywords []string
+}
+
+func parseFilter(q url.Values) (filter, error) {
+	var f filter
+	var err error
+	f.sources = q["source"]
+	for _, t := range q["tag"] {
+		f.tags = append(f.tags, strings.ToLower(t))
+	}
+	if v := q.Get("since"); v != "" {
+		if f.since, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid since: %s", v)
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.until, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, fmt.Errorf("invalid until: %s", v)
+		}
+	}
+	f.keywords = strings.Fields(strings.ToLower(q.Get("q")))
+	return f, nil
+}
+
+func (f *filter) empty() bool {
+	return len(f.sources) == 0 && len(f.tags) == 0 && f.since.IsZero() && f.until.IsZero() && len(f.keywords) == 0
+}
+
+func (f *filter) match(e *structs.Entry) bool {
+	if len(f.sources) > 0 && !contains(f.sources, e.Source) {
+		return false
+	}
+	if len(f.tags) > 0 {
+		found := false
+		for _, t := range e.Tags {
+			if contains(f.tags, strings.ToLower(t)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if !f.since.IsZero() && e.CreatedAt.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !e.CreatedAt.Before(f.until) {
+		return false
+	}
+	if len(f.keywords) > 0 {
+		text := strings.ToLower(e.Title + "\n" + e.Summary)
+		for _, k := range f.keywords {
+			if !strings.Contains(text, k) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// apply returns the entries matching f keeping their order
+func (f *filter) apply(entries []structs.Entry) []structs.Entry {
+	if f.empty() {
+		return entries
+	}
+	matched := []structs.Entry{}
+	for i := range entries {
+		if f.match(&entries[i]) {
+			matched = append(matched, entries[i])
+		}
+	}
+	return matched
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
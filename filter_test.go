@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+var filterData = []structs.Entry{
+	{ID: "qiita:1", Source: "qiita", Title: "Go context", Summary: "cancel propagation", Tags: []string{"Go"}, CreatedAt: time.Date(2018, 11, 20, 0, 0, 0, 0, time.UTC)},
+	{ID: "hatenablog:2", Source: "hatenablog", Title: "Docker build", Summary: "multi stage build of go binary", Tags: []string{"docker", "go"}, CreatedAt: time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC)},
+	{ID: "hatenablog:3", Source: "hatenablog", Title: "Year in review", Summary: "", CreatedAt: time.Date(2017, 12, 31, 0, 0, 0, 0, time.UTC)},
+}
+
+func TestFilter(t *testing.T) {
+	cases := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{name: "no filter", query: "", want: []string{"qiita:1", "hatenablog:2", "hatenablog:3"}},
+		{name: "source", query: "source=hatenablog", want: []string{"hatenablog:2", "hatenablog:3"}},
+		{name: "sources", query: "source=qiita&source=hatenablog", want: []string{"qiita:1", "hatenablog:2", "hatenablog:3"}},
+		{name: "tag", query: "tag=go", want: []string{"qiita:1", "hatenablog:2"}},
+		{name: "since", query: "since=2018-01-01T00:00:00Z", want: []string{"qiita:1", "hatenablog:2"}},
+		{name: "until", query: "until=2018-06-01T00:00:00Z", want: []string{"hatenablog:3"}},
+		{name: "keyword in title", query: "q=CONTEXT", want: []string{"qiita:1"}},
+		{name: "keywords in summary", query: "q=go+binary", want: []string{"hatenablog:2"}},
+		{name: "combined", query: "tag=go&since=2018-01-01T00:00:00Z&source=hatenablog", want: []string{"hatenablog:2"}},
+		{name: "no match", query: "source=medium", want: []string{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tc.query)
+			f, err := parseFilter(q)
+			if err != nil {
+				t.Fatal("parseFilter failed: ", err)
+			}
+			got := []string{}
+			for _, e := range f.apply(filterData) {
+				got = append(got, e.ID)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v; want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, query := range []string{"since=2018-01-01", "until=yesterday"} {
+		t.Run(query, func(t *testing.T) {
+			q, _ := url.ParseQuery(query)
+			if _, err := parseFilter(q); err == nil {
+				t.Fatal("got nil; want error")
+			}
+		})
+	}
+}
+
+func TestHandleEntries_FilterUsesCache(t *testing.T) {
+	s := server{
+		logger: log.New(os.Stdout, "", log.Lshortfile),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	p := &stubProvider{name: "stub:testuser", entries: filterData}
+	s.blogService.Add(p)
+
+	handler := s.handleEntries()
+
+	for _, query := range []string{"", "source=qiita", "tag=go", "q=docker&limit=1"} {
+		req, err := http.NewRequest("GET", "/api/v1/entries?"+query, nil)
+		if err != nil {
+			t.Fatal("NewRequest failed: ", err.Error())
+		}
+		rec := httptest.NewRecorder()
+
+		handler.ServeHTTP(rec, req)
+
+		if got, want := rec.Code, http.StatusOK; got != want {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+		var e entriesResponse
+		if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+			t.Fatal("json Decode failed: ", err)
+		}
+		q, _ := url.ParseQuery(query)
+		f, _ := parseFilter(q)
+		if got, want := e.Total, len(f.apply(filterData)); got != want {
+			t.Fatalf("%s: got %v, want %v", query, got, want)
+		}
+	}
+
+	if got, want := p.calls, int32(1); got != want {
+		t.Fatalf("got %v fetches, want %v", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"sync"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// flightGroup collapses concurrent fetches with the same key into one.
+// The shared fetch is canceled only when every waiter has given up.
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+	wg    sync.WaitGroup
+}
+
+type flightCall struct {
+	key     string
+	done    chan struct{}
+	cancel  context.CancelFunc
+	waiters int
+
+	entries []structs.Entry
+	err     error
+}
+
+// join starts fn for key unless a call for key is in flight, and returns the call.
+// The caller is counted as a waiter of the call.
+func (g *flightGroup) join(key string, fn func(ctx context.Context) ([]structs.Entry, error)) *flightCall {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		c.waiters++
+		return c
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &flightCall{key: key, done: make(chan struct{}), cancel: cancel, waiters: 1}
+	g.calls[key] = c
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		c.entries, c.err = fn(ctx)
+
+		g.mu.Lock()
+		g.forget(c)
+		g.mu.Unlock()
+		close(c.done)
+		cancel()
+	}()
+
+	return c
+}
+
+// wait waits for c until ctx is done.
+// A call canceled by its last waiter is forgotten at once, so that later callers start a new call.
+func (g *flightGroup) wait(ctx context.Context, c *flightCall) ([]structs.Entry, error) {
+	select {
+	case <-c.done:
+		return c.entries, c.err
+	case <-ctx.Done():
+		g.mu.Lock()
+		c.waiters--
+		if c.waiters == 0 {
+			c.cancel()
+			g.forget(c)
+		}
+		g.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// forget removes c unless a new call for the key has replaced it. g.mu must be held.
+func (g *flightGroup) forget(c *flightCall) {
+	if g.calls[c.key] == c {
+		delete(g.calls, c.key)
+	}
+}
+
+// do calls fn for key sharing the result with the concurrent callers with the same key
+func (g *flightGroup) do(ctx context.Context, key string, fn func(ctx context.Context) ([]structs.Entry, error)) ([]structs.Entry, error) {
+	return g.wait(ctx, g.join(key, fn))
+}
+
+// drain waits for every call in flight to finish
+func (g *flightGroup) drain() {
+	g.wg.Wait()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// httpProvider fetches entries in json from url
+type httpProvider struct {
+	url string
+}
+
+func (p *httpProvider) Name() string { return "http:" + p.url }
+func (p *httpProvider) Kind() string { return "http" }
+func (p *httpProvider) Fetch(ctx context.Context) ([]structs.Entry, error) {
+	req, err := http.NewRequest("GET", p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := blogservice.HTTPClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var entries []structs.Entry
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+func TestHandleEntries_CollapseCacheMiss(t *testing.T) {
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		time.Sleep(100 * time.Millisecond)
+		json.NewEncoder(w).Encode([]structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}})
+	}))
+	defer upstream.Close()
+
+	s := server{
+		logger: log.New(os.Stdout, "", log.Lshortfile),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	s.blogService.Add(&httpProvider{url: upstream.URL})
+	handler := s.handleEntries()
+
+	const n = 20
+	var wg sync.WaitGroup
+	counts := make([]int, n)
+	for i := 0; i < n; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequest("GET", "/api/v1/entries", nil)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			var e entriesResponse
+			if err := json.NewDecoder(rec.Body).Decode(&e); err == nil {
+				counts[i] = len(e.Entries)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got, want := atomic.LoadInt32(&hits), int32(1); got != want {
+		t.Fatalf("got %v upstream hits, want %v", got, want)
+	}
+	for i, c := range counts {
+		if c != 1 {
+			t.Fatalf("got %v entries in response %d, want 1", c, i)
+		}
+	}
+}
+
+func TestFlightGroup_Cancel(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	fn := func(ctx context.Context) ([]structs.Entry, error) {
+		close(started)
+		<-ctx.Done()
+		close(canceled)
+		return nil, ctx.Err()
+	}
+
+	ctx1, cancel1 := context.WithCancel(context.Background())
+	ctx2, cancel2 := context.WithCancel(context.Background())
+	c := g.join("key", fn)
+	<-started
+	g.join("key", fn)
+
+	errCh := make(chan error, 2)
+	go func() { _, err := g.wait(ctx1, c); errCh <- err }()
+	go func() { _, err := g.wait(ctx2, c); errCh <- err }()
+
+	cancel1()
+	<-errCh
+	select {
+	case <-canceled:
+		t.Fatal("shared fetch is canceled while a waiter remains")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel2()
+	<-errCh
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("shared fetch is not canceled after every waiter gave up")
+	}
+	g.drain()
+}
+
+func TestFlightGroup_JoinAfterCancel(t *testing.T) {
+	var g flightGroup
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+	// the canceled call takes a while to return
+	slow := func(ctx context.Context) ([]structs.Entry, error) {
+		close(started)
+		<-ctx.Done()
+		<-release
+		return nil, ctx.Err()
+	}
+	fast := func(ctx context.Context) ([]structs.Entry, error) {
+		return []structs.Entry{{Title: "a"}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := g.join("key", slow)
+	<-started
+	cancel()
+	if _, err := g.wait(ctx, c); err != context.Canceled {
+		t.Fatalf("got %v, want %v", err, context.Canceled)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	e, err := g.do(ctx, "key", fast)
+	if err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if got, want := len(e), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	close(release)
+	g.drain()
+	if got, want := len(g.calls), 0; got != want {
+		t.Fatalf("got %v calls, want %v", got, want)
+	}
+}
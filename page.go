@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+const maxLimit = 100
+
+// cursor points an entry in the timeline sorted by newest.
+// A next cursor pages the entries after the entry, and a prev cursor pages the entries before it.
+type cursor struct {
+	prev      bool
+	createdAt time.Time
+	id        string
+}
+
+func newCursor(e structs.Entry, prev bool) *cursor {
+	return &cursor{prev: prev, createdAt: e.CreatedAt, id: e.ID}
+}
+
+func (c *cursor) String() string {
+	dir := "n"
+	if c.prev {
+		dir = "p"
+	}
+	v := strings.Join([]string{dir, c.createdAt.Format(time.RFC3339Nano), c.id}, "|")
+	return base64.RawURLEncoding.EncodeToString([]byte(v))
+}
+
+func parseCursor(s string) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	v := strings.SplitN(string(b), "|", 3)
+	if len(v) != 3 || (v[0] != "n" && v[0] != "p") {
+		return nil, errors.New("invalid cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, v[1])
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	return &cursor{prev: v[0] == "p", createdAt: createdAt, id: v[2]}, nil
+}
+
+// newer reports whether a sorts before b in the timeline
+func newer(a, b structs.Entry) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// sortEntries sorts entries by newest
+func sortEntries(entries []structs.Entry) {
+	sort.Slice(entries, func(i, j int) bool {
+		return newer(entries[i], entries[j])
+	})
+}
+
+// sortedCopy returns a copy of entries sorted by newest
+func sortedCopy(entries []structs.Entry) []structs.Entry {
+	c := make([]structs.Entry, len(entries))
+	copy(c, entries)
+	sortEntries(c)
+	return c
+}
+
+// mergeEntries merges lists sorted by newest into a new list sorted by newest
+func mergeEntries(lists [][]structs.Entry) []structs.Entry {
+	var n int
+	for _, l := range lists {
+		n += len(l)
+	}
+	merged := make([]structs.Entry, 0, n)
+	heads := make([]int, len(lists))
+	for len(merged) < n {
+		k := -1
+		for i, l := range lists {
+			if heads[i] == len(l) {
+				continue
+			}
+			if k < 0 || newer(l[heads[i]], lists[k][heads[k]]) {
+				k = i
+			}
+		}
+		merged = append(merged, lists[k][heads[k]])
+		heads[k]++
+	}
+	return merged
+}
+
+// pageQuery is the paging parameters of the entries endpoint
+type pageQuery struct {
+	limit  int
+	offset int
+	cursor *cursor
+}
+
+func parsePageQuery(q url.Values) (pageQuery, error) {
+	var pq pageQuery
+	var err error
+	if v := q.Get("limit"); v != "" {
+		if pq.limit, err = strconv.Atoi(v); err != nil || pq.limit <= 0 {
+			return pq, fmt.Errorf("invalid limit: %s", v)
+		}
+		if pq.limit > maxLimit {
+			pq.limit = maxLimit
+		}
+	}
+	if v := q.Get("offset"); v != "" {
+		if pq.offset, err = strconv.Atoi(v); err != nil || pq.offset < 0 {
+			return pq, fmt.Errorf("invalid offset: %s", v)
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		if pq.offset > 0 {
+			return pq, errors.New("offset and cursor are exclusive")
+		}
+		if pq.cursor, err = parseCursor(v); err != nil {
+			return pq, err
+		}
+	}
+	return pq, nil
+}
+
+// paginate returns the page of entries sorted by newest, and the cursors of the next and prev pages.
+// The cursors are nil if there is no such page.
+func paginate(entries []structs.Entry, pq pageQuery) (page []structs.Entry, next, prev *cursor) {
+	start, end := pq.offset, len(entries)
+	if c := pq.cursor; c != nil {
+		key := structs.Entry{CreatedAt: c.createdAt, ID: c.id}
+		// the index of the first entry after key
+		i := sort.Search(len(entries), func(i int) bool { return newer(key, entries[i]) })
+		if c.prev {
+			// the index of key, or of the first entry after key if key was removed
+			end = sort.Search(len(entries), func(i int) bool { return !newer(entries[i], key) })
+			start = 0
+			if pq.limit > 0 && end-pq.limit > 0 {
+				start = end - pq.limit
+			}
+		} else {
+			start = i
+		}
+	}
+	if start > len(entries) {
+		start = len(entries)
+	}
+	if pq.limit > 0 && start+pq.limit < end {
+		end = start + pq.limit
+	}
+
+	page = entries[start:end]
+	if len(page) == 0 {
+		return page, nil, nil
+	}
+	if end < len(entries) {
+		next = newCursor(page[len(page)-1], false)
+	}
+	if start > 0 {
+		prev = newCursor(page[0], true)
+	}
+	return page, next, prev
+}
+
+// pageURL returns self with the cursor replacing the paging parameters
+func pageURL(self *url.URL, c *cursor) string {
+	if c == nil {
+		return ""
+	}
+	u := *self
+	q := u.Query()
+	q.Del("offset")
+	q.Set("cursor", c.String())
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// timeline returns n entries sorted by newest. Every pair of entries has the same created time.
+func timeline(n int) []structs.Entry {
+	entries := make([]structs.Entry, n)
+	for i := range entries {
+		entries[i] = structs.Entry{
+			ID:        fmt.Sprintf("stub:%02d", i),
+			Title:     fmt.Sprintf("%02d", i),
+			CreatedAt: now.Add(-time.Duration(i/2) * time.Hour),
+		}
+	}
+	sortEntries(entries)
+	return entries
+}
+
+func titles(entries []structs.Entry) []string {
+	var t []string
+	for _, e := range entries {
+		t = append(t, e.Title)
+	}
+	return t
+}
+
+func TestPaginate(t *testing.T) {
+	entries := timeline(7)
+	all := titles(entries)
+
+	cases := []struct {
+		name     string
+		query    string
+		want     []string
+		wantNext bool
+		wantPrev bool
+	}{
+		{name: "no paging", query: "", want: all},
+		{name: "limit", query: "limit=3", want: all[:3], wantNext: true},
+		{name: "offset", query: "offset=5", want: all[5:], wantPrev: true},
+		{name: "limit and offset", query: "limit=3&offset=2", want: all[2:5], wantNext: true, wantPrev: true},
+		{name: "offset out of range", query: "offset=10", want: []string{}},
+		{name: "next cursor", query: "limit=2&cursor=" + newCursor(entries[2], false).String(), want: all[3:5], wantNext: true, wantPrev: true},
+		{name: "prev cursor", query: "limit=2&cursor=" + newCursor(entries[3], true).String(), want: all[1:3], wantNext: true, wantPrev: true},
+		{name: "prev cursor at head", query: "limit=5&cursor=" + newCursor(entries[3], true).String(), want: all[:3], wantNext: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, _ := url.ParseQuery(tc.query)
+			pq, err := parsePageQuery(q)
+			if err != nil {
+				t.Fatal("parsePageQuery failed: ", err)
+			}
+			page, next, prev := paginate(entries, pq)
+			if got := titles(page); len(got) != len(tc.want) || (len(got) > 0 && !reflect.DeepEqual(got, tc.want)) {
+				t.Fatalf("got %v; want %v", got, tc.want)
+			}
+			if got := next != nil; got != tc.wantNext {
+				t.Fatalf("got next %v; want %v", next, tc.wantNext)
+			}
+			if got := prev != nil; got != tc.wantPrev {
+				t.Fatalf("got prev %v; want %v", prev, tc.wantPrev)
+			}
+		})
+	}
+}
+
+func TestPaginate_Walk(t *testing.T) {
+	entries := timeline(7)
+	pq := pageQuery{limit: 3}
+
+	var got []string
+	var pages []pageQuery
+	for {
+		pages = append(pages, pq)
+		page, next, _ := paginate(entries, pq)
+		got = append(got, titles(page)...)
+		if next == nil {
+			break
+		}
+		pq = pageQuery{limit: 3, cursor: next}
+	}
+	if want := titles(entries); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+
+	// walk back from the last page
+	_, _, prev := paginate(entries, pq)
+	page, _, _ := paginate(entries, pageQuery{limit: 3, cursor: prev})
+	if got, want := titles(page), titles(entries[3:6]); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v; want %v", got, want)
+	}
+}
+
+func TestParsePageQuery_Invalid(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=a", "offset=-1", "cursor=!!!", "cursor=eA", "offset=1&cursor=" + newCursor(timeline(1)[0], false).String()} {
+		t.Run(query, func(t *testing.T) {
+			q, _ := url.ParseQuery(query)
+			if _, err := parsePageQuery(q); err == nil {
+				t.Fatal("got nil; want error")
+			}
+		})
+	}
+}
+
+func TestHandleEntries_Limit(t *testing.T) {
+	rec := serveFeed(t, "/api/v1/entries?limit=2", "")
+
+	var e entriesResponse
+	if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+		t.Fatal("json Decode failed: ", err)
+	}
+	if got, want := len(e.Entries), 2; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if got, want := e.Total, len(feedData); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if e.Next == "" || e.Prev != "" {
+		t.Fatalf("got next %q prev %q; want next only", e.Next, e.Prev)
+	}
+	if got, want := rec.HeaderMap.Get("Link"), fmt.Sprintf(`<%s>; rel="next"`, e.Next); got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	next, err := url.Parse(e.Next)
+	if err != nil {
+		t.Fatal("url Parse failed: ", err)
+	}
+	rec = serveFeed(t, next.RequestURI(), "")
+	e = entriesResponse{}
+	if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+		t.Fatal("json Decode failed: ", err)
+	}
+	if got, want := len(e.Entries), 1; got != want {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	if e.Next != "" || e.Prev == "" {
+		t.Fatalf("got next %q prev %q; want prev only", e.Next, e.Prev)
+	}
+}
+
+func TestMergeEntries(t *testing.T) {
+	entries := timeline(6)
+	all := titles(entries)
+
+	cases := []struct {
+		name  string
+		lists [][]structs.Entry
+		want  []string
+	}{
+		{name: "no list", lists: nil, want: nil},
+		{name: "empty lists", lists: [][]structs.Entry{{}, nil}, want: nil},
+		{name: "one list", lists: [][]structs.Entry{entries}, want: all},
+		{name: "interleaved", lists: [][]structs.Entry{{entries[0], entries[2], entries[4]}, {entries[1], entries[3], entries[5]}}, want: all},
+		{name: "same created time", lists: [][]structs.Entry{{entries[1]}, {entries[0]}, {entries[2], entries[3]}}, want: all[:4]},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := titles(mergeEntries(tc.lists)); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
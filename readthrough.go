@@ -0,0 +1,85 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// readThroughStorage keeps the items of a persistent storage in memory,
+// so that the storage is read and decoded only on a miss.
+// Items of a storage shared with other processes are read again after ttl, since the others may set them.
+// Zero ttl keeps items until they expire.
+type readThroughStorage struct {
+	storage
+	maxStale time.Duration
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	items map[string]localItem
+	// sets counts Set, so that an item read from the storage does not replace one set meanwhile
+	sets int
+}
+
+type localItem struct {
+	content    []structs.Entry
+	expiration time.Time
+	// loaded is when the item is read from or set to the storage
+	loaded time.Time
+}
+
+func newReadThroughStorage(s storage, maxStale, ttl time.Duration) *readThroughStorage {
+	return &readThroughStorage{
+		storage:  s,
+		maxStale: maxStale,
+		ttl:      ttl,
+		items:    make(map[string]localItem),
+	}
+}
+
+func (s *readThroughStorage) Get(key string) ([]structs.Entry, time.Time, state) {
+	s.mu.Lock()
+	i, ok := s.items[key]
+	sets := s.sets
+	s.mu.Unlock()
+	now := time.Now()
+	if ok && (s.ttl == 0 || now.Sub(i.loaded) < s.ttl) {
+		if st := freshness(i.expiration.UnixNano(), now.UnixNano(), s.maxStale); st != missing {
+			return i.content, i.expiration, st
+		}
+	}
+
+	content, expiration, st := s.storage.Get(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sets == sets {
+		if st == missing {
+			delete(s.items, key)
+		} else {
+			s.items[key] = localItem{content: content, expiration: expiration, loaded: now}
+		}
+	}
+	return content, expiration, st
+}
+
+// Set writes the item to the storage without the lock, so that a slow storage does not block Get.
+// A Get which has read the storage meanwhile does not keep the item it has read.
+func (s *readThroughStorage) Set(key string, content []structs.Entry, duration time.Duration) {
+	s.storage.Set(key, content, duration)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sets++
+	now := time.Now()
+	s.items[key] = localItem{content: content, expiration: now.Add(duration), loaded: now}
+}
+
+// TryLock takes the lock in the storage if it is shared with other processes
+func (s *readThroughStorage) TryLock(key string, ttl time.Duration) bool {
+	if l, ok := s.storage.(locker); ok {
+		return l.TryLock(key, ttl)
+	}
+	return true
+}
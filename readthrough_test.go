@@ -0,0 +1,153 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/redis/redistest"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// countingStorage counts the reads of a storage
+type countingStorage struct {
+	storage
+	gets int32
+}
+
+func (s *countingStorage) Get(key string) ([]structs.Entry, time.Time, state) {
+	atomic.AddInt32(&s.gets, 1)
+	return s.storage.Get(key)
+}
+
+// blockingStorage blocks Set until release is closed
+type blockingStorage struct {
+	storage
+	setting chan struct{}
+	release chan struct{}
+}
+
+func (s *blockingStorage) Set(key string, content []structs.Entry, duration time.Duration) {
+	close(s.setting)
+	<-s.release
+	s.storage.Set(key, content, duration)
+}
+
+func TestReadThroughStorage(t *testing.T) {
+	f, teardown := newTestFileStorage(t, 60*time.Second)
+	defer teardown()
+
+	content := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+	}
+	// items kept by the previous process
+	f.Set("kept", content, 60*time.Second)
+	f.Set("stale", content, -30*time.Second)
+
+	backend := &countingStorage{storage: f}
+	s := newReadThroughStorage(backend, 60*time.Second, 0)
+	s.Set("set", content, 60*time.Second)
+
+	cases := []struct {
+		key       string
+		wantState state
+		wantLen   int
+		wantGets  int32
+	}{
+		{key: "set", wantState: fresh, wantLen: 1, wantGets: 0},
+		{key: "kept", wantState: fresh, wantLen: 1, wantGets: 1},
+		{key: "stale", wantState: stale, wantLen: 1, wantGets: 1},
+		{key: "unknown", wantState: missing, wantLen: 0, wantGets: 3},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.key, func(t *testing.T) {
+			atomic.StoreInt32(&backend.gets, 0)
+			for i := 0; i < 3; i++ {
+				c, _, st := s.Get(tc.key)
+				if st != tc.wantState {
+					t.Fatalf("got %v; want %v", st, tc.wantState)
+				}
+				if len(c) != tc.wantLen {
+					t.Fatalf("got %v; want %v entries", c, tc.wantLen)
+				}
+			}
+			if got := atomic.LoadInt32(&backend.gets); got != tc.wantGets {
+				t.Fatalf("got %v reads of the storage; want %v", got, tc.wantGets)
+			}
+		})
+	}
+
+	// Set writes through to the storage
+	s.Set("kept", nil, 60*time.Second)
+	if c, _, _ := f.Get("kept"); len(c) != 0 {
+		t.Fatalf("got %v; want the item replaced", c)
+	}
+	if c, _, _ := s.Get("kept"); len(c) != 0 {
+		t.Fatalf("got %v; want the item replaced", c)
+	}
+}
+
+func TestReadThroughStorage_Shared(t *testing.T) {
+	r := redistest.NewServer("")
+	defer r.Close()
+	a := newReadThroughStorage(newTestRedisStorage(t, r, 60*time.Second), 60*time.Second, 50*time.Millisecond)
+	defer a.Close()
+	b := newTestRedisStorage(t, r, 60*time.Second)
+	defer b.Close()
+
+	key := GenerateCacheKey("stub:testuser", "stub")
+	a.Set(key, []structs.Entry{{Title: "a"}}, 60*time.Second)
+	b.Set(key, []structs.Entry{{Title: "a"}, {Title: "b"}}, 60*time.Second)
+
+	// the item set by another replica is read after ttl
+	if c, _, _ := a.Get(key); len(c) != 1 {
+		t.Fatalf("got %v; want the item kept in memory", c)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if c, _, _ := a.Get(key); len(c) != 2 {
+		t.Fatalf("got %v; want the item set by another replica", c)
+	}
+
+	if !a.TryLock("lock", time.Second) {
+		t.Fatal("got false; want the lock taken")
+	}
+	if b.TryLock("lock", time.Second) {
+		t.Fatal("got true; want the lock held in redis")
+	}
+}
+
+func TestReadThroughStorage_SlowSet(t *testing.T) {
+	f, teardown := newTestFileStorage(t, 60*time.Second)
+	defer teardown()
+	f.Set("other", []structs.Entry{{Title: "b"}}, 60*time.Second)
+
+	backend := &blockingStorage{storage: f, setting: make(chan struct{}), release: make(chan struct{})}
+	s := newReadThroughStorage(backend, 60*time.Second, 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Set("key", []structs.Entry{{Title: "a"}}, 60*time.Second)
+	}()
+	<-backend.setting
+
+	got := make(chan state, 1)
+	go func() {
+		_, _, st := s.Get("other")
+		got <- st
+	}()
+	select {
+	case st := <-got:
+		if st != fresh {
+			t.Fatalf("got %v; want %v", st, fresh)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("got Get blocked by a slow Set")
+	}
+
+	close(backend.release)
+	<-done
+	if c, _, st := s.Get("key"); st != fresh || len(c) != 1 {
+		t.Fatalf("got %v %v; want the item set", c, st)
+	}
+}
@@ -0,0 +1,258 @@
+// Package redis is a minimal client of redis speaking RESP
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is the timeout of connecting and each command
+const DefaultTimeout = 5 * time.Second
+
+const maxIdleConns = 8
+
+// Error is an error reply of redis
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Client is a redis client. It is safe for concurrent use.
+// Connections are pooled up to maxIdleConns.
+type Client struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+
+	mu     sync.Mutex
+	idle   []*conn
+	closed bool
+}
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// NewClient returns a client of the redis server at rawurl
+// in the form of redis://[:password@]host[:port][/db]
+func NewClient(rawurl string, timeout time.Duration) (*Client, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis url: %s", err.Error())
+	}
+	if u.Scheme != "redis" {
+		return nil, errors.New("invalid scheme in redis url")
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	var password string
+	if u.User != nil {
+		password, _ = u.User.Password()
+	}
+	var db int
+	if p := strings.TrimPrefix(u.Path, "/"); p != "" {
+		if db, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("invalid redis db: %s", p)
+		}
+	}
+	return &Client{
+		addr:     addr,
+		password: password,
+		db:       db,
+		timeout:  timeout,
+	}, nil
+}
+
+// Do sends a command and returns the reply.
+// A reply is a string, an int64, a []byte, a []interface{} or nil.
+// An error reply is returned as an Error, and an error element of an array reply is an Error in it.
+// The command is sent again on a new connection if an idle connection turns out to be closed by the server.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	cn, err := c.idleConn()
+	if err != nil {
+		return nil, err
+	}
+	if cn != nil {
+		reply, err := c.do(cn, args)
+		if !closedByServer(err) {
+			return reply, err
+		}
+	}
+
+	if cn, err = c.dial(); err != nil {
+		return nil, err
+	}
+	return c.do(cn, args)
+}
+
+// do sends a command on cn and releases cn, or closes cn if it fails
+func (c *Client) do(cn *conn, args []string) (interface{}, error) {
+	reply, err := cn.do(c.timeout, args...)
+	if _, ok := err.(Error); err != nil && !ok {
+		cn.Close()
+		return nil, err
+	}
+	c.release(cn)
+	return reply, err
+}
+
+// closedByServer reports whether err is of a connection closed by the server, e.g. after its idle timeout.
+// The server has not run the command then, unlike a timeout.
+func closedByServer(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	e, ok := err.(*net.OpError)
+	return ok && !e.Timeout()
+}
+
+// Close closes the idle connections. Connections in use are closed when they are released.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	for _, cn := range c.idle {
+		cn.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// idleConn takes an idle connection from the pool, or returns nil if there is none
+func (c *Client) idleConn() (*conn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, errors.New("redis client is closed")
+	}
+	n := len(c.idle)
+	if n == 0 {
+		return nil, nil
+	}
+	cn := c.idle[n-1]
+	c.idle = c.idle[:n-1]
+	return cn, nil
+}
+
+// dial opens a connection authenticated and selecting the db
+func (c *Client) dial() (*conn, error) {
+	nc, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		if _, err := cn.do(c.timeout, "AUTH", c.password); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(c.timeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			cn.Close()
+			return nil, err
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) release(cn *conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed || len(c.idle) >= maxIdleConns {
+		cn.Close()
+		return
+	}
+	c.idle = append(c.idle, cn)
+}
+
+func (cn *conn) do(timeout time.Duration, args ...string) (interface{}, error) {
+	if timeout > 0 {
+		cn.SetDeadline(time.Now().Add(timeout))
+	}
+	if _, err := io.WriteString(cn, encodeCommand(args)); err != nil {
+		return nil, err
+	}
+	return readReply(cn.r)
+}
+
+// encodeCommand encodes a command as an array of bulk strings
+func encodeCommand(args []string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	return b.String()
+}
+
+// readReply reads a reply
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || !strings.HasSuffix(line, "\r\n") {
+		return nil, fmt.Errorf("invalid redis reply: %q", line)
+	}
+	line = line[:len(line)-2]
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis reply: %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		// the whole array is read even if it has error elements, so that the next reply starts at its own line
+		a := make([]interface{}, n)
+		for i := range a {
+			v, err := readReply(r)
+			if e, ok := err.(Error); ok {
+				v = e
+			} else if err != nil {
+				return nil, err
+			}
+			a[i] = v
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("invalid redis reply: %q", line)
+}
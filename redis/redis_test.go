@@ -0,0 +1,155 @@
+package redis
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/redis/redistest"
+)
+
+func TestNewClient(t *testing.T) {
+	cases := []struct {
+		url          string
+		wantAddr     string
+		wantPassword string
+		wantDB       int
+		wantErr      bool
+	}{
+		{url: "redis://localhost", wantAddr: "localhost:6379"},
+		{url: "redis://localhost:6380/2", wantAddr: "localhost:6380", wantDB: 2},
+		{url: "redis://:secret@redis.example.com:6379/0", wantAddr: "redis.example.com:6379", wantPassword: "secret"},
+		{url: "http://localhost:6379", wantErr: true},
+		{url: "redis://localhost:6379/db", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.url, func(t *testing.T) {
+			c, err := NewClient(tc.url, time.Second)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want error", c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("NewClient failed: ", err)
+			}
+			if c.addr != tc.wantAddr || c.password != tc.wantPassword || c.db != tc.wantDB {
+				t.Fatalf("got %v %v %v, want %v %v %v", c.addr, c.password, c.db, tc.wantAddr, tc.wantPassword, tc.wantDB)
+			}
+		})
+	}
+}
+
+func TestClient_Do(t *testing.T) {
+	r := redistest.NewServer("secret")
+	defer r.Close()
+
+	c, err := NewClient(r.URL(), time.Second)
+	if err != nil {
+		t.Fatal("NewClient failed: ", err)
+	}
+	defer c.Close()
+
+	if reply, err := c.Do("PING"); err != nil || reply != "PONG" {
+		t.Fatalf("got %v %v, want PONG", reply, err)
+	}
+	if reply, err := c.Do("SET", "key", "a\r\nb"); err != nil || reply != "OK" {
+		t.Fatalf("got %v %v, want OK", reply, err)
+	}
+	if reply, err := c.Do("GET", "key"); err != nil || string(reply.([]byte)) != "a\r\nb" {
+		t.Fatalf("got %v %v, want a\\r\\nb", reply, err)
+	}
+	if reply, err := c.Do("GET", "unknown"); err != nil || reply != nil {
+		t.Fatalf("got %v %v, want nil", reply, err)
+	}
+	if reply, err := c.Do("DEL", "key", "unknown"); err != nil || reply != int64(1) {
+		t.Fatalf("got %v %v, want 1", reply, err)
+	}
+	if _, err := c.Do("UNKNOWN"); err == nil {
+		t.Fatal("got nil, want error reply")
+	} else if _, ok := err.(Error); !ok {
+		t.Fatalf("got %v, want Error", err)
+	}
+
+	if got, want := r.Conns(), 1; got != want {
+		t.Fatalf("got %v connections, want %v", got, want)
+	}
+}
+
+func TestClient_AuthFailed(t *testing.T) {
+	r := redistest.NewServer("secret")
+	defer r.Close()
+
+	c, err := NewClient("redis://:wrong@"+r.Addr(), time.Second)
+	if err != nil {
+		t.Fatal("NewClient failed: ", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("PING"); err == nil {
+		t.Fatal("got nil, want error")
+	}
+}
+
+func TestClient_ClosedByServer(t *testing.T) {
+	r := redistest.NewServer("secret")
+	defer r.Close()
+
+	c, err := NewClient(r.URL(), time.Second)
+	if err != nil {
+		t.Fatal("NewClient failed: ", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Do("SET", "key", "a"); err != nil {
+		t.Fatal("SET failed: ", err)
+	}
+	// the idle connection in the pool is closed by the server
+	r.CloseConns()
+	time.Sleep(10 * time.Millisecond)
+
+	if reply, err := c.Do("GET", "key"); err != nil || string(reply.([]byte)) != "a" {
+		t.Fatalf("got %v %v, want a", reply, err)
+	}
+	if got, want := r.Conns(), 2; got != want {
+		t.Fatalf("got %v connections, want %v", got, want)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	cases := []struct {
+		name    string
+		replies string
+		want    []interface{}
+	}{
+		{name: "simple", replies: "+OK\r\n:1\r\n", want: []interface{}{"OK", int64(1)}},
+		{name: "bulk", replies: "$3\r\na\r\n\r\n$-1\r\n", want: []interface{}{[]byte("a\r\n"), nil}},
+		{name: "error", replies: "-ERR bad\r\n+OK\r\n", want: []interface{}{Error("ERR bad"), "OK"}},
+		{
+			name:    "error in array",
+			replies: "*3\r\n-ERR bad\r\n:1\r\n$1\r\nb\r\n+OK\r\n",
+			want:    []interface{}{[]interface{}{Error("ERR bad"), int64(1), []byte("b")}, "OK"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := bufio.NewReader(strings.NewReader(tc.replies))
+			for _, want := range tc.want {
+				got, err := readReply(r)
+				if e, ok := err.(Error); ok {
+					got = e
+				} else if err != nil {
+					t.Fatal("readReply failed: ", err)
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Fatalf("got %#v, want %#v", got, want)
+				}
+			}
+		})
+	}
+}
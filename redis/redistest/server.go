@@ -0,0 +1,249 @@
+// Package redistest provides an in-process redis server for tests
+package redistest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is an in-process stand-in of a redis server
+// supporting PING, AUTH, SELECT, GET, SET with NX, PX or EX, DEL and PTTL
+type Server struct {
+	ln       net.Listener
+	password string
+
+	mu     sync.Mutex
+	values map[string]value
+	conns  int
+	open   map[net.Conn]bool
+}
+
+type value struct {
+	value    string
+	expireAt time.Time
+}
+
+// NewServer starts a server which requires password unless it is empty.
+// The caller should call Close when finished.
+func NewServer(password string) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(fmt.Sprintf("redistest: failed to listen: %s", err.Error()))
+	}
+	s := &Server{
+		ln:       ln,
+		password: password,
+		values:   make(map[string]value),
+		open:     make(map[net.Conn]bool),
+	}
+	go s.serve()
+	return s
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.ln.Close()
+}
+
+// Addr returns the address of the server
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// URL returns the url of the server with the password and db 1
+func (s *Server) URL() string {
+	if s.password != "" {
+		return fmt.Sprintf("redis://:%s@%s/1", s.password, s.Addr())
+	}
+	return "redis://" + s.Addr()
+}
+
+// Keys returns the keys stored in the server
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var keys []string
+	for k := range s.values {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// CloseConns closes the open connections as the idle timeout of redis does
+func (s *Server) CloseConns() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for conn := range s.open {
+		conn.Close()
+	}
+}
+
+// Conns returns the number of connections accepted by the server
+func (s *Server) Conns() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns++
+		s.open[conn] = true
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.open, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	br := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(br)
+		if err != nil || len(args) == 0 {
+			return
+		}
+
+		cmd := strings.ToUpper(args[0])
+		var reply string
+		switch {
+		case cmd == "AUTH":
+			if len(args) == 2 && args[1] == s.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-ERR invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = s.exec(cmd, args[1:])
+		}
+		if _, err := conn.Write([]byte(reply)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) exec(cmd string, args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k, v := range s.values {
+		if !v.expireAt.IsZero() && time.Now().After(v.expireAt) {
+			delete(s.values, k)
+		}
+	}
+
+	switch {
+	case cmd == "PING":
+		return "+PONG\r\n"
+	case cmd == "SELECT" && len(args) == 1:
+		return "+OK\r\n"
+	case cmd == "GET" && len(args) == 1:
+		v, ok := s.values[args[0]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(v.value), v.value)
+	case cmd == "SET" && len(args) >= 2:
+		v := value{value: args[1]}
+		var nx bool
+		for i := 2; i < len(args); i++ {
+			opt := strings.ToUpper(args[i])
+			if opt == "NX" {
+				nx = true
+				continue
+			}
+			if i+1 == len(args) || (opt != "PX" && opt != "EX") {
+				return "-ERR syntax error\r\n"
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n <= 0 {
+				return "-ERR invalid expire time in set\r\n"
+			}
+			unit := time.Millisecond
+			if opt == "EX" {
+				unit = time.Second
+			}
+			v.expireAt = time.Now().Add(time.Duration(n) * unit)
+			i++
+		}
+		if _, ok := s.values[args[0]]; ok && nx {
+			return "$-1\r\n"
+		}
+		s.values[args[0]] = v
+		return "+OK\r\n"
+	case cmd == "DEL":
+		var n int
+		for _, k := range args {
+			if _, ok := s.values[k]; ok {
+				delete(s.values, k)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case cmd == "PTTL" && len(args) == 1:
+		v, ok := s.values[args[0]]
+		switch {
+		case !ok:
+			return ":-2\r\n"
+		case v.expireAt.IsZero():
+			return ":-1\r\n"
+		}
+		return fmt.Sprintf(":%d\r\n", time.Until(v.expireAt)/time.Millisecond)
+	}
+	return fmt.Sprintf("-ERR unknown command '%s'\r\n", cmd)
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(r *bufio.Reader) ([]string, error) {
+	n, err := readHeader(r, '*')
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readHeader(r, '$')
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, size+2)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		args[i] = string(b[:size])
+	}
+	return args, nil
+}
+
+// readHeader reads a line of the given type and returns its length
+func readHeader(r *bufio.Reader, typ byte) (int, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 3 || line[0] != typ || !strings.HasSuffix(line, "\r\n") {
+		return 0, fmt.Errorf("redistest: invalid command: %q", line)
+	}
+	return strconv.Atoi(line[1 : len(line)-2])
+}
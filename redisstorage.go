@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/redis"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// redisStorage is a storage in redis, so that replicas share the cache.
+// An item expires in redis when it gets expired beyond the max stale.
+type redisStorage struct {
+	client   *redis.Client
+	maxStale time.Duration
+	logger   *log.Logger
+}
+
+func newRedisStorage(client *redis.Client, maxStale time.Duration, logger *log.Logger) *redisStorage {
+	return &redisStorage{
+		client:   client,
+		maxStale: maxStale,
+		logger:   logger,
+	}
+}
+
+func (s *redisStorage) Get(key string) ([]structs.Entry, time.Time, state) {
+	reply, err := s.client.Do("GET", key)
+	if err != nil {
+		s.logger.Printf("[ERROR] %s %s", key, err.Error())
+		return nil, time.Time{}, missing
+	}
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, time.Time{}, missing
+	}
+
+	var i storedItem
+	if err := json.Unmarshal(data, &i); err != nil {
+		s.logger.Printf("[ERROR] %s %s", key, err.Error())
+		return nil, time.Time{}, missing
+	}
+	st := freshness(i.Expiration.UnixNano(), time.Now().UnixNano(), s.maxStale)
+	if st == missing {
+		return nil, time.Time{}, missing
+	}
+	return i.Content, i.Expiration, st
+}
+
+func (s *redisStorage) Set(key string, content []structs.Entry, duration time.Duration) {
+	if content == nil {
+		content = []structs.Entry{}
+	}
+
+	ttl := duration + s.maxStale
+	if ttl < time.Millisecond {
+		if _, err := s.client.Do("DEL", key); err != nil {
+			s.logger.Printf("[ERROR] %s %s", key, err.Error())
+		}
+		return
+	}
+
+	data, err := json.Marshal(&storedItem{
+		Key:        key,
+		Expiration: time.Now().Add(duration),
+		Content:    content,
+	})
+	if err != nil {
+		s.logger.Printf("[ERROR] %s %s", key, err.Error())
+		return
+	}
+	if _, err := s.client.Do("SET", key, string(data), "PX", strconv.FormatInt(int64(ttl/time.Millisecond), 10)); err != nil {
+		s.logger.Printf("[ERROR] %s %s", key, err.Error())
+	}
+}
+
+// TryLock takes the lock of key with SET NX, which expires after ttl.
+// The lock is taken if redis is unavailable, since refreshing twice is better than not at all.
+func (s *redisStorage) TryLock(key string, ttl time.Duration) bool {
+	if ttl < time.Millisecond {
+		ttl = time.Millisecond
+	}
+	reply, err := s.client.Do("SET", key, strconv.FormatInt(time.Now().UnixNano(), 10), "NX", "PX", strconv.FormatInt(int64(ttl/time.Millisecond), 10))
+	if err != nil {
+		s.logger.Printf("[ERROR] %s %s", key, err.Error())
+		return true
+	}
+	return reply != nil
+}
+
+// Close closes the connections to redis
+func (s *redisStorage) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/redis"
+	"github.com/shiimaxx/blog-aggregator/redis/redistest"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+func newTestRedisStorage(t *testing.T, r *redistest.Server, maxStale time.Duration) *redisStorage {
+	client, err := redis.NewClient(r.URL(), time.Second)
+	if err != nil {
+		t.Fatal("NewClient failed: ", err)
+	}
+	return newRedisStorage(client, maxStale, log.New(ioutil.Discard, "", 0))
+}
+
+func TestRedisStorage_State(t *testing.T) {
+	r := redistest.NewServer("")
+	defer r.Close()
+	s := newTestRedisStorage(t, r, 60*time.Second)
+	defer s.Close()
+
+	content := []structs.Entry{
+		{Title: "a", URL: "https://example.com/a", CreatedAt: now},
+	}
+	s.Set("fresh", content, 60*time.Second)
+	s.Set("stale", content, -30*time.Second)
+	s.Set("expired", content, -90*time.Second)
+	s.Set("empty", []structs.Entry{}, 60*time.Second)
+
+	cases := []struct {
+		key       string
+		wantState state
+		wantLen   int
+		wantTTL   time.Duration
+	}{
+		{key: "fresh", wantState: fresh, wantLen: 1, wantTTL: 120 * time.Second},
+		{key: "stale", wantState: stale, wantLen: 1, wantTTL: 30 * time.Second},
+		{key: "expired", wantState: missing, wantLen: 0, wantTTL: -2 * time.Millisecond},
+		{key: "empty", wantState: fresh, wantLen: 0, wantTTL: 120 * time.Second},
+		{key: "unknown", wantState: missing, wantLen: 0, wantTTL: -2 * time.Millisecond},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.key, func(t *testing.T) {
+			c, _, st := s.Get(tc.key)
+			if st != tc.wantState {
+				t.Fatalf("got %v; want %v", st, tc.wantState)
+			}
+			if len(c) != tc.wantLen {
+				t.Fatalf("got %v; want %v entries", c, tc.wantLen)
+			}
+
+			reply, err := s.client.Do("PTTL", tc.key)
+			if err != nil {
+				t.Fatal("PTTL failed: ", err)
+			}
+			ttl := time.Duration(reply.(int64)) * time.Millisecond
+			if ttl > tc.wantTTL || ttl < tc.wantTTL-time.Second {
+				t.Fatalf("got %v; want %v", ttl, tc.wantTTL)
+			}
+		})
+	}
+}
+
+func TestRedisStorage_Shared(t *testing.T) {
+	r := redistest.NewServer("secret")
+	defer r.Close()
+	a := newTestRedisStorage(t, r, 60*time.Second)
+	defer a.Close()
+	b := newTestRedisStorage(t, r, 60*time.Second)
+	defer b.Close()
+
+	content := []structs.Entry{
+		{
+			ID:        "stub:1",
+			Source:    "stub",
+			Title:     "a",
+			URL:       "https://example.com/a",
+			Tags:      []string{"go"},
+			CreatedAt: now.UTC().Truncate(time.Second),
+			UpdatedAt: now.UTC().Truncate(time.Second),
+		},
+	}
+	key := GenerateCacheKey("stub:testuser", "stub")
+	a.Set(key, content, 60*time.Second)
+
+	c, _, st := b.Get(key)
+	if st != fresh {
+		t.Fatalf("got %v; want %v", st, fresh)
+	}
+	if !reflect.DeepEqual(c, content) {
+		t.Fatalf("got %v; want %v", c, content)
+	}
+
+	keys := r.Keys()
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], "ba:stub:") {
+		t.Fatalf("got %v; want a key prefixed with ba:stub:", keys)
+	}
+}
+
+func TestRedisStorage_Unavailable(t *testing.T) {
+	r := redistest.NewServer("")
+	s := newTestRedisStorage(t, r, 60*time.Second)
+	defer s.Close()
+	r.Close()
+
+	s.Set("key", []structs.Entry{{Title: "a"}}, 60*time.Second)
+	if c, _, st := s.Get("key"); st != missing || c != nil {
+		t.Fatalf("got %v %v; want missing", c, st)
+	}
+}
+
+func TestRedisStorage_TryLock(t *testing.T) {
+	r := redistest.NewServer("")
+	defer r.Close()
+	a := newTestRedisStorage(t, r, 60*time.Second)
+	defer a.Close()
+	b := newTestRedisStorage(t, r, 60*time.Second)
+	defer b.Close()
+
+	if !a.TryLock("lock", 50*time.Millisecond) {
+		t.Fatal("got false; want the lock taken")
+	}
+	if b.TryLock("lock", 50*time.Millisecond) {
+		t.Fatal("got true; want the lock held by another")
+	}
+	time.Sleep(60 * time.Millisecond)
+	if !b.TryLock("lock", 50*time.Millisecond) {
+		t.Fatal("got false; want the expired lock taken")
+	}
+
+	r.Close()
+	if !a.TryLock("unavailable", 50*time.Millisecond) {
+		t.Fatal("got false; want the lock taken while redis is unavailable")
+	}
+}
+
+func TestServer_RefreshReplicas(t *testing.T) {
+	r := redistest.NewServer("")
+	defer r.Close()
+
+	// steps are the error of the fetch of each replica in turn
+	cases := []struct {
+		name      string
+		errs      []error
+		wantCalls []int32
+	}{
+		// the first replica refreshes the shared cache and the others find it fresh
+		{name: "refreshed", errs: []error{nil, nil, nil}, wantCalls: []int32{1, 0, 0}},
+		// the others wait for the lock of the first replica to expire
+		{name: "failed", errs: []error{errRefresh, nil, nil}, wantCalls: []int32{1, 0, 0}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for i, err := range tc.errs {
+				s := server{
+					logger:      log.New(ioutil.Discard, "", 0),
+					cache:       newTestRedisStorage(t, r, 60*time.Second),
+					blogService: &blogservice.BlogService{},
+				}
+				p := &stubProvider{name: "stub:" + tc.name, entries: []structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}}, err: err}
+				s.blogService.Add(p)
+				s.scheduler = newScheduler(s.blogService.Providers, time.Minute, s.refresh)
+
+				s.refresh(context.Background(), p)
+				s.cache.Close()
+				if got, want := atomic.LoadInt32(&p.calls), tc.wantCalls[i]; got != want {
+					t.Fatalf("replica %d: got %v fetches, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleEntries_RedisStorage(t *testing.T) {
+	r := redistest.NewServer("")
+	defer r.Close()
+
+	// replicas share the cache, so only the first one fetches
+	for i := 0; i < 3; i++ {
+		s := server{
+			logger:      log.New(ioutil.Discard, "", 0),
+			cache:       newTestRedisStorage(t, r, 60*time.Second),
+			blogService: &blogservice.BlogService{},
+		}
+		p := &stubProvider{name: "stub:testuser", entries: []structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}}}
+		s.blogService.Add(p)
+
+		req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+		if err != nil {
+			t.Fatal("NewRequest failed: ", err.Error())
+		}
+		rec := httptest.NewRecorder()
+		s.handleEntries().ServeHTTP(rec, req)
+		s.cache.Close()
+
+		var e entriesResponse
+		if err := json.NewDecoder(rec.Body).Decode(&e); err != nil {
+			t.Fatal("json Decode failed: ", err)
+		}
+		if got, want := len(e.Entries), 1; got != want {
+			t.Fatalf("replica %d: got %v entries, want %v", i, got, want)
+		}
+		want := int32(0)
+		if i == 0 {
+			want = 1
+		}
+		if got := p.calls; got != want {
+			t.Fatalf("replica %d: got %v fetches, want %v", i, got, want)
+		}
+	}
+}
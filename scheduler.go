@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+)
+
+// clock abstracts time so that tests can control the scheduler
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// scheduler refreshes every provider in background on its own interval
+type scheduler struct {
+	providers []blogservice.Provider
+	// refresh fetches entries from a provider and stores them
+	refresh func(ctx context.Context, p blogservice.Provider)
+	// interval is the default interval of refreshes
+	interval time.Duration
+	// jitter spreads refreshes by up to the fraction of the interval
+	jitter float64
+	clock  clock
+
+	mu     sync.Mutex
+	rand   *rand.Rand
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newScheduler(providers []blogservice.Provider, interval time.Duration, refresh func(ctx context.Context, p blogservice.Provider)) *scheduler {
+	return &scheduler{
+		providers: providers,
+		refresh:   refresh,
+		interval:  interval,
+		jitter:    0.1,
+		clock:     realClock{},
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Start refreshes every provider immediately and then on its interval until Stop is called.
+// refresh decides whether a refresh fetches the provider.
+func (sc *scheduler) Start() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	sc.cancel = cancel
+	for _, p := range sc.providers {
+		p := p
+		sc.wg.Add(1)
+		go func() {
+			defer sc.wg.Done()
+			sc.run(ctx, p)
+		}()
+	}
+}
+
+// Stop cancels running refreshes and waits for them to return
+func (sc *scheduler) Stop() {
+	sc.mu.Lock()
+	cancel := sc.cancel
+	sc.cancel = nil
+	sc.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	sc.wg.Wait()
+}
+
+func (sc *scheduler) run(ctx context.Context, p blogservice.Provider) {
+	for {
+		sc.refresh(ctx, p)
+		select {
+		case <-ctx.Done():
+			return
+		case <-sc.clock.After(sc.next(p)):
+		}
+	}
+}
+
+// intervalOf returns the refresh interval of p
+func (sc *scheduler) intervalOf(p blogservice.Provider) time.Duration {
+	if c, ok := p.(blogservice.Configurable); ok && c.Options().RefreshInterval > 0 {
+		return c.Options().RefreshInterval
+	}
+	return sc.interval
+}
+
+// next returns the duration until the next refresh of p
+func (sc *scheduler) next(p blogservice.Provider) time.Duration {
+	interval := sc.intervalOf(p)
+	if sc.jitter <= 0 {
+		return interval
+	}
+
+	sc.mu.Lock()
+	r := sc.rand.Float64()
+	sc.mu.Unlock()
+	return interval + time.Duration(float64(interval)*sc.jitter*(2*r-1))
+}
+
+// maxNext returns the longest duration until the next refresh of p
+func (sc *scheduler) maxNext(p blogservice.Provider) time.Duration {
+	interval := sc.intervalOf(p)
+	return interval + time.Duration(float64(interval)*sc.jitter)
+}
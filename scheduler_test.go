@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+type fakeWaiter struct {
+	deadline time.Time
+	ch       chan time.Time
+}
+
+// fakeClock is a clock which advances only by Advance
+type fakeClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []fakeWaiter
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	c.waiters = append(c.waiters, fakeWaiter{deadline: c.now.Add(d), ch: ch})
+	return ch
+}
+
+// Advance moves the clock forward by d and fires the waiters whose deadline has come
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	var waiters []fakeWaiter
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			waiters = append(waiters, w)
+			continue
+		}
+		w.ch <- c.now
+	}
+	c.waiters = waiters
+}
+
+// BlockUntil waits until n goroutines are waiting on the clock
+func (c *fakeClock) BlockUntil(t *testing.T, n int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		waiting := len(c.waiters)
+		c.mu.Unlock()
+		if waiting >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for %d waiters", n)
+}
+
+type intervalProvider struct {
+	stubProvider
+	interval time.Duration
+}
+
+func (p *intervalProvider) Options() blogservice.Options {
+	return blogservice.Options{RefreshInterval: p.interval}
+}
+
+func TestScheduler(t *testing.T) {
+	fast := &stubProvider{name: "stub:fast"}
+	slow := &intervalProvider{stubProvider: stubProvider{name: "stub:slow"}, interval: 5 * time.Minute}
+
+	var mu sync.Mutex
+	refreshed := map[string]int{}
+	sc := newScheduler([]blogservice.Provider{fast, slow}, time.Minute, func(ctx context.Context, p blogservice.Provider) {
+		mu.Lock()
+		refreshed[p.Name()]++
+		mu.Unlock()
+	})
+	clk := &fakeClock{now: now}
+	sc.clock = clk
+	sc.jitter = 0
+
+	assertRefreshed := func(wantFast, wantSlow int) {
+		t.Helper()
+		mu.Lock()
+		defer mu.Unlock()
+		if got := refreshed["stub:fast"]; got != wantFast {
+			t.Fatalf("got %v refreshes of fast; want %v", got, wantFast)
+		}
+		if got := refreshed["stub:slow"]; got != wantSlow {
+			t.Fatalf("got %v refreshes of slow; want %v", got, wantSlow)
+		}
+	}
+
+	sc.Start()
+	clk.BlockUntil(t, 2)
+	assertRefreshed(1, 1)
+
+	for i := 1; i <= 4; i++ {
+		clk.Advance(time.Minute)
+		clk.BlockUntil(t, 2)
+		assertRefreshed(1+i, 1)
+	}
+
+	clk.Advance(time.Minute)
+	clk.BlockUntil(t, 2)
+	assertRefreshed(6, 2)
+
+	sc.Stop()
+	clk.Advance(time.Hour)
+	assertRefreshed(6, 2)
+}
+
+func TestScheduler_Jitter(t *testing.T) {
+	sc := newScheduler(nil, time.Minute, nil)
+	p := &stubProvider{name: "stub:testuser"}
+
+	min, max := 54*time.Second, 66*time.Second
+	for i := 0; i < 1000; i++ {
+		if d := sc.next(p); d < min || d > max {
+			t.Fatalf("got %v; want between %v and %v", d, min, max)
+		}
+	}
+}
+
+var errRefresh = errors.New("refresh failed")
+
+func TestServer_Refresh(t *testing.T) {
+	s := server{
+		logger: log.New(os.Stdout, "", log.Lshortfile),
+		cache: &memStorage{
+			items: make(map[string]item),
+			mu:    &sync.RWMutex{},
+		},
+		blogService: &blogservice.BlogService{},
+	}
+	p := &stubProvider{name: "stub:testuser", entries: []structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}}}
+	s.blogService.Add(p)
+	s.scheduler = newScheduler(s.blogService.Providers, time.Minute, s.refresh)
+
+	s.refresh(context.Background(), p)
+
+	// a failed refresh keeps the last good snapshot
+	entries, _, _ := s.cache.Get(sourceCacheKey(p))
+	s.cache.Set(sourceCacheKey(p), entries, 30*time.Second)
+	p.entries, p.err = nil, errRefresh
+	s.refresh(context.Background(), p)
+
+	req, err := http.NewRequest("GET", "/api/v1/entries", nil)
+	if err != nil {
+		t.Fatal("NewRequest failed: ", err.Error())
+	}
+	rec := httptest.NewRecorder()
+	s.handleEntries().ServeHTTP(rec, req)
+
+	if got, want := atomic.LoadInt32(&p.calls), int32(2); got != want {
+		t.Fatalf("got %v fetches, want %v", got, want)
+	}
+	if got, _, _ := s.cache.Get(sourceCacheKey(p)); len(got) != 1 {
+		t.Fatalf("got %v; want the last good snapshot", got)
+	}
+}
+
+func TestServer_RefreshOnStart(t *testing.T) {
+	f, teardown := newTestFileStorage(t, time.Hour)
+	defer teardown()
+
+	entries := []structs.Entry{{Title: "a", URL: "https://example.com/a", CreatedAt: now}}
+	cases := []struct {
+		name      string
+		cached    time.Duration
+		wantCalls int32
+	}{
+		{name: "fresh", cached: 2 * time.Minute, wantCalls: 0},
+		{name: "fresh until the next refresh", cached: 70 * time.Second, wantCalls: 0},
+		{name: "stale before the next refresh", cached: 60 * time.Second, wantCalls: 1},
+		{name: "stale", cached: -time.Second, wantCalls: 1},
+		{name: "missing", wantCalls: 1},
+	}
+
+	s := server{
+		logger:      log.New(ioutil.Discard, "", 0),
+		cache:       f,
+		blogService: &blogservice.BlogService{},
+	}
+	providers := make([]*stubProvider, len(cases))
+	for i, tc := range cases {
+		providers[i] = &stubProvider{name: "stub:" + tc.name, entries: entries}
+		s.blogService.Add(providers[i])
+		// the cache kept by the previous process
+		if tc.cached != 0 {
+			f.Set(sourceCacheKey(providers[i]), entries, tc.cached)
+		}
+	}
+	s.scheduler = newScheduler(s.blogService.Providers, time.Minute, s.refresh)
+	// the clock stops as the cache is kept, so that the margins to the next refresh are exact
+	clk := &fakeClock{now: time.Now()}
+	s.scheduler.clock = clk
+
+	s.scheduler.Start()
+	defer s.scheduler.Stop()
+	clk.BlockUntil(t, len(cases))
+
+	for i, tc := range cases {
+		if got := atomic.LoadInt32(&providers[i].calls); got != tc.wantCalls {
+			t.Fatalf("%s: got %v fetches, want %v", tc.name, got, tc.wantCalls)
+		}
+	}
+
+	// the kept caches get stale before the refresh after the next one
+	clk.Advance(time.Minute + 6*time.Second)
+	clk.BlockUntil(t, len(cases))
+	for i := range cases[:2] {
+		if got, want := atomic.LoadInt32(&providers[i].calls), int32(1); got != want {
+			t.Fatalf("%s: got %v fetches, want %v", cases[i].name, got, want)
+		}
+	}
+}
@@ -3,7 +3,16 @@ package structs
 import "time"
 
 type Entry struct {
+	// ID identifies the entry across blog services, e.g. "qiita:c686397e4a0f4f11683d"
+	ID string `json:"id"`
+	// Source is the blog service kind of the entry, e.g. "qiita"
+	Source    string    `json:"source"`
 	Title     string    `json:"title"`
 	URL       string    `json:"url"`
+	Author    string    `json:"author,omitempty"`
+	Summary   string    `json:"summary,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
+	Thumbnail string    `json:"thumbnail,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
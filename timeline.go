@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shiimaxx/blog-aggregator/blogservice"
+	"github.com/shiimaxx/blog-aggregator/structs"
+)
+
+// sourceCacheKey returns the cache key of the entries of p
+func sourceCacheKey(p blogservice.Provider) string {
+	return GenerateCacheKey(p.Name(), p.Kind())
+}
+
+// errNoSources is returned by timeline when no provider is configured
+var errNoSources = errors.New("no blog service is configured")
+
+// upstreamError is returned by timeline when every provider failed
+type upstreamError struct {
+	errs []error
+}
+
+func (e *upstreamError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+	return "every blog service failed: " + strings.Join(msgs, ", ")
+}
+
+// timeout reports whether every provider timed out
+func (e *upstreamError) timeout() bool {
+	for _, err := range e.errs {
+		if !blogservice.IsTimeout(err) {
+			return false
+		}
+	}
+	return true
+}
+
+// snapshot is the merged entries of every provider sorted by newest
+type snapshot struct {
+	entries []structs.Entry
+	// expiration is when the first of the merged entries gets stale
+	expiration time.Time
+	// sources is the status of every provider as of the merged entries
+	sources []blogservice.Source
+}
+
+// lastModified returns the newest updated time of the entries
+func (s *snapshot) lastModified() time.Time {
+	var t time.Time
+	for _, e := range s.entries {
+		if u := updatedAt(e); u.After(t) {
+			t = u
+		}
+	}
+	return t
+}
+
+// timeline returns the snapshot of the entries of every provider.
+// The cached entries are immutable snapshots, so the merged entries are a new slice
+// which the caller is free to modify.
+// Providers missing in the cache are fetched concurrently and cached per provider.
+// Stale providers are served from the cache and revalidated in background.
+// Failed providers are left out of the timeline, and an upstreamError is returned if every provider failed.
+func (s *server) timeline(ctx context.Context) (*snapshot, error) {
+	providers := s.blogService.Providers
+	if len(providers) == 0 {
+		return nil, errNoSources
+	}
+	contents := make([][]structs.Entry, len(providers))
+	expirations := make([]time.Time, len(providers))
+	errs := make([]error, len(providers))
+	cached := make([]bool, len(providers))
+
+	var wg sync.WaitGroup
+	for i, p := range providers {
+		c, exp, st := s.cache.Get(sourceCacheKey(p))
+		switch st {
+		case fresh:
+			contents[i], expirations[i], cached[i] = c, exp, true
+			continue
+		case stale:
+			contents[i], expirations[i], cached[i] = c, exp, true
+			s.revalidate(p)
+			continue
+		}
+
+		i, p := i, p
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.logger.Printf("[INFO] %s %s", p.Name(), "cache miss")
+			contents[i], errs[i] = s.fetchSource(ctx, p)
+			// failed providers are fetched again by the next request
+			expirations[i] = time.Now()
+			if errs[i] == nil {
+				expirations[i] = expirations[i].Add(s.cacheExpiration(p))
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == len(providers) {
+		return nil, &upstreamError{errs: failed}
+	}
+
+	snap := &snapshot{entries: mergeEntries(contents), sources: s.blogService.Sources()}
+	for _, exp := range expirations {
+		if snap.expiration.IsZero() || exp.Before(snap.expiration) {
+			snap.expiration = exp
+		}
+	}
+	for i, p := range providers {
+		src := &snap.sources[i]
+		src.Count = len(contents[i])
+		// the cached entries may have been fetched by another process or before a restart,
+		// and then the status of the last fetch in this process is outdated
+		if fetchedAt := expirations[i].Add(-s.cacheExpiration(p)); cached[i] && fetchedAt.After(src.FetchedAt) {
+			src.FetchedAt, src.Err = fetchedAt, nil
+		}
+	}
+	return snap, nil
+}
+
+// revalidate refreshes the stale cache of p in background unless p is already being fetched
+// or is backing off by its rate limit. The stale cache is kept if the refresh fails.
+func (s *server) revalidate(p blogservice.Provider) {
+	if s.blogService.BackingOff(p) {
+		return
+	}
+	s.flight.join(sourceCacheKey(p), s.fetcher(p))
+}
+
+// fetchSource fetches entries from p and caches them. The cache is kept on failure.
+// Concurrent fetches from p are collapsed into one.
+func (s *server) fetchSource(ctx context.Context, p blogservice.Provider) ([]structs.Entry, error) {
+	return s.flight.do(ctx, sourceCacheKey(p), s.fetcher(p))
+}
+
+// fetcher returns the function which fetches entries from p and caches them
+func (s *server) fetcher(p blogservice.Provider) func(ctx context.Context) ([]structs.Entry, error) {
+	return func(ctx context.Context) ([]structs.Entry, error) {
+		e, err := s.blogService.FetchSource(ctx, p)
+		// fetches short-circuited or backed off are not logged, since the failures which opened the circuit
+		// and the rate limit which the provider backs off by are logged by the fetches before them.
+		// Fetches canceled since nobody waits for them are not failures of p.
+		skipped := err == blogservice.ErrCircuitOpen || blogservice.IsRateLimited(err) || ctx.Err() == context.Canceled
+		if l, ok := p.(blogservice.RateLimiter); ok && !skipped {
+			if rl, ok := l.RateLimit(); ok {
+				s.logger.Printf("[INFO] %s rate limit %d of %d remaining until %s", p.Name(), rl.Remaining, rl.Limit, rl.Reset.Format(time.RFC3339))
+			}
+		}
+		if err != nil {
+			if !skipped {
+				s.logger.Printf("[ERROR] %s %s", p.Name(), err.Error())
+			}
+			return nil, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// the provider may keep e, so the cache holds a sorted copy which is never modified
+		e = sortedCopy(e)
+		s.cache.Set(sourceCacheKey(p), e, s.cacheExpiration(p))
+		return e, nil
+	}
+}
+
+// refresh updates the cache of p. It is called by the scheduler.
+// It does nothing while the cache stays fresh until the next refresh, e.g. the cache has been
+// kept over a restart or refreshed by another replica, or while another replica is refreshing p.
+func (s *server) refresh(ctx context.Context, p blogservice.Provider) {
+	if !s.refreshDue(p) {
+		s.logger.Printf("[INFO] %s %s", p.Name(), "refresh skipped for fresh cache")
+		return
+	}
+	// replicas sharing the cache take turns, and the others find the cache fresh on their next refresh
+	if l, ok := s.cache.(locker); ok && s.scheduler != nil && !l.TryLock(sourceCacheKey(p)+":lock", s.scheduler.intervalOf(p)/2) {
+		s.logger.Printf("[INFO] %s %s", p.Name(), "refresh skipped for another replica")
+		return
+	}
+	if _, err := s.fetchSource(ctx, p); err == nil {
+		s.logger.Printf("[INFO] %s %s", p.Name(), "refreshed")
+	}
+}
+
+// refreshDue reports whether the cache of p would get stale before the next refresh
+func (s *server) refreshDue(p blogservice.Provider) bool {
+	if s.scheduler == nil {
+		return true
+	}
+	_, exp, st := s.cache.Get(sourceCacheKey(p))
+	return st != fresh || exp.Sub(s.scheduler.clock.Now()) <= s.scheduler.maxNext(p)
+}
+
+// cacheExpiration returns how long fetched entries of p are fresh.
+// Entries refreshed by the scheduler are fresh until the refresh after next is due,
+// so that they get stale only if refreshes fail.
+func (s *server) cacheExpiration(p blogservice.Provider) time.Duration {
+	if s.scheduler != nil {
+		return 2 * s.scheduler.intervalOf(p)
+	}
+	return defaultCacheExpiration
+}